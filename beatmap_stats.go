@@ -0,0 +1,223 @@
+package gosu
+
+import (
+	"math"
+	"sort"
+)
+
+// Metrics derived from the hit objects of a parsed .osu file, for use when
+// comparing maps against each other.
+
+// Width of the windows NoteDensity is computed over
+const NoteDensityWindowMsec = 1000
+
+type BeatmapStats struct {
+	// The highest combo reachable on the map
+	MaxCombo int
+
+	// Streams are runs of at least MinStreamLength circles each at most a
+	// 1/4 beat apart.
+	NumStreams    int
+	LongestStream int
+	// Fraction of all hit objects which are part of a stream
+	StreamDensity float64
+
+	// Jumps are consecutive objects at most a 1/2 beat apart whose spacing is
+	// at least JumpDistanceRadii circle radii.
+	NumJumps int
+	// Fraction of all hit objects which are the target of a jump
+	JumpDensity float64
+
+	// Msec spent at each BPM, rounded to two decimal places
+	BPMDistribution map[float64]int
+	// The number of times the slider velocity changes
+	NumSliderVelocityChanges int
+
+	// The number of objects starting in each NoteDensityWindowMsec window,
+	// beginning at the first hit object.
+	NoteDensity []int
+	// The highest number of objects per second over all windows
+	PeakNoteDensity float64
+
+	// Statistics of the distance (in osu!pixels) between the start
+	// positions of consecutive hit objects, spinners excluded.
+	Spacing SpacingStats
+}
+
+type SpacingStats struct {
+	Mean   float64
+	StdDev float64
+	Min    float64
+	Max    float64
+}
+
+const (
+	MinStreamLength   = 3
+	JumpDistanceRadii = 4
+)
+
+// Compute the BeatmapStats of a parsed .osu file
+func ComputeBeatmapStats(osu *OsuFile) BeatmapStats {
+	stats := BeatmapStats{BPMDistribution: make(map[float64]int)}
+	if len(osu.HitObjects) == 0 {
+		return stats
+	}
+	// The metrics walk the objects in time order, which the .osu file does
+	// not guarantee.
+	less := func(objects []HitObject) func(i, j int) bool {
+		return func(i, j int) bool { return objects[i].TimeMsec < objects[j].TimeMsec }
+	}
+	if !sort.SliceIsSorted(osu.HitObjects, less(osu.HitObjects)) {
+		sorted := *osu
+		sorted.HitObjects = append([]HitObject(nil), osu.HitObjects...)
+		sort.SliceStable(sorted.HitObjects, less(sorted.HitObjects))
+		osu = &sorted
+	}
+
+	timing := osu.TimingMap()
+	for i := range osu.HitObjects {
+		stats.MaxCombo += osu.comboOf(&osu.HitObjects[i], timing)
+	}
+	osu.computeStreamsAndJumps(&stats, timing)
+	osu.computeTimingStats(&stats, timing)
+	osu.computeNoteDensity(&stats)
+	osu.computeSpacing(&stats)
+	return stats
+}
+
+// The radius of a circle in osu!pixels for the map's circle size
+func (this *OsuFile) CircleRadius() float64 {
+	return 54.4 - 4.48*this.CircleSize
+}
+
+// Returns the amount of combo the hit object is worth
+func (this *OsuFile) comboOf(h *HitObject, timing *TimingMap) int {
+	if !h.IsSlider() {
+		return 1
+	}
+	sv := timing.SliderVelocityAt(float64(h.TimeMsec))
+	ticksPerSpan := 0
+	if this.SliderTickRate > 0 {
+		tickDistance := 100 * this.SliderMultiplier * sv / this.SliderTickRate
+		if tickDistance > 0 {
+			ticksPerSpan = int(math.Ceil(h.Length/tickDistance)) - 1
+		}
+	}
+	if ticksPerSpan < 0 {
+		ticksPerSpan = 0
+	}
+	slides := h.Slides
+	if slides < 1 {
+		slides = 1
+	}
+	// head + ticks on every span + every repeat + tail
+	return 1 + ticksPerSpan*slides + (slides - 1) + 1
+}
+
+func (this *OsuFile) computeStreamsAndJumps(stats *BeatmapStats, timing *TimingMap) {
+	jumpDistance := JumpDistanceRadii * this.CircleRadius()
+	streamLength := 1
+	inStreams := 0
+	endStream := func() {
+		if streamLength >= MinStreamLength {
+			stats.NumStreams++
+			inStreams += streamLength
+			if streamLength > stats.LongestStream {
+				stats.LongestStream = streamLength
+			}
+		}
+		streamLength = 1
+	}
+
+	for i := 1; i < len(this.HitObjects); i++ {
+		prev := &this.HitObjects[i-1]
+		cur := &this.HitObjects[i]
+		beatLength := timing.beatLengthOrDefault(float64(cur.TimeMsec))
+		gap := float64(cur.TimeMsec - this.endTimeOf(prev, timing))
+
+		if prev.IsCircle() && cur.IsCircle() && gap <= beatLength/4+1 {
+			streamLength++
+		} else {
+			endStream()
+		}
+
+		if !prev.IsSpinner() && !cur.IsSpinner() && gap <= beatLength/2+1 &&
+			distance(prev, cur) >= jumpDistance {
+			stats.NumJumps++
+		}
+	}
+	endStream()
+
+	numObjects := float64(len(this.HitObjects))
+	stats.StreamDensity = float64(inStreams) / numObjects
+	stats.JumpDensity = float64(stats.NumJumps) / numObjects
+}
+
+func (this *OsuFile) computeTimingStats(stats *BeatmapStats, timing *TimingMap) {
+	lastSv := 1.0
+	for i := range timing.points {
+		if sv := timing.points[i].SliderVelocityMultiplier(); sv != lastSv {
+			stats.NumSliderVelocityChanges++
+			lastSv = sv
+		}
+	}
+
+	start := float64(this.HitObjects[0].TimeMsec)
+	for bpm, duration := range timing.bpmDurations(start, timing.endMsec) {
+		stats.BPMDistribution[math.Round(bpm*100)/100] += int(duration)
+	}
+}
+
+func (this *OsuFile) computeNoteDensity(stats *BeatmapStats) {
+	start := this.HitObjects[0].TimeMsec
+	last := this.HitObjects[len(this.HitObjects)-1].TimeMsec
+	stats.NoteDensity = make([]int, (last-start)/NoteDensityWindowMsec+1)
+	for i := range this.HitObjects {
+		window := (this.HitObjects[i].TimeMsec - start) / NoteDensityWindowMsec
+		stats.NoteDensity[window]++
+	}
+	peak := 0
+	for _, count := range stats.NoteDensity {
+		if count > peak {
+			peak = count
+		}
+	}
+	stats.PeakNoteDensity = float64(peak) * 1000 / NoteDensityWindowMsec
+}
+
+func (this *OsuFile) computeSpacing(stats *BeatmapStats) {
+	var distances []float64
+	var prev *HitObject
+	for i := range this.HitObjects {
+		cur := &this.HitObjects[i]
+		if cur.IsSpinner() {
+			prev = nil
+			continue
+		}
+		if prev != nil {
+			distances = append(distances, distance(prev, cur))
+		}
+		prev = cur
+	}
+	if len(distances) == 0 {
+		return
+	}
+
+	stats.Spacing.Min = math.Inf(1)
+	sum := 0.0
+	for _, d := range distances {
+		sum += d
+		stats.Spacing.Min = math.Min(stats.Spacing.Min, d)
+		stats.Spacing.Max = math.Max(stats.Spacing.Max, d)
+	}
+	stats.Spacing.Mean = sum / float64(len(distances))
+	variance := 0.0
+	for _, d := range distances {
+		variance += (d - stats.Spacing.Mean) * (d - stats.Spacing.Mean)
+	}
+	stats.Spacing.StdDev = math.Sqrt(variance / float64(len(distances)))
+}
+
+func distance(a, b *HitObject) float64 {
+	return math.Hypot(float64(a.X-b.X), float64(a.Y-b.Y))
+}
@@ -0,0 +1,84 @@
+package gosu
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testOsuFilePath = "data/Songs/1 gosu - Test Song/gosu - Test Song (Stymphalian) [Normal].osu"
+
+func TestComputeBeatmapStats(t *testing.T) {
+	osu, err := ReadOsuFile(testOsuFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := ComputeBeatmapStats(osu)
+
+	testcases := []struct {
+		Name string
+		Got  interface{}
+		Want interface{}
+	}{
+		{"MaxCombo", stats.MaxCombo, 19},
+		{"NumStreams", stats.NumStreams, 1},
+		{"LongestStream", stats.LongestStream, 5},
+		{"NumJumps", stats.NumJumps, 0},
+		{"NumSliderVelocityChanges", stats.NumSliderVelocityChanges, 2},
+		{"BPMDistribution", stats.BPMDistribution, map[float64]int{120: 12000, 240: 500}},
+		{"NoteDensity", stats.NoteDensity, []int{5, 2, 2, 0, 1, 0, 1, 0, 0, 0, 0, 0, 3}},
+		{"PeakNoteDensity", stats.PeakNoteDensity, 5.0},
+		{"Spacing.Min", stats.Spacing.Min, 0.0},
+	}
+	for _, testcase := range testcases {
+		if !reflect.DeepEqual(testcase.Got, testcase.Want) {
+			t.Errorf("%s: got %v, want %v", testcase.Name, testcase.Got, testcase.Want)
+		}
+	}
+}
+
+func TestComputeBeatmapStatsJumps(t *testing.T) {
+	osu := &OsuFile{
+		CircleSize:       4,
+		SliderMultiplier: 1.4,
+		SliderTickRate:   1,
+		TimingPoints:     []OsuFileTimingPoint{{BeatLength: 500, Meter: 4, Uninherited: true}},
+		HitObjects: []HitObject{
+			{X: 0, Y: 0, TimeMsec: 0, Type: HitObjectCircle},
+			{X: 400, Y: 0, TimeMsec: 250, Type: HitObjectCircle},
+			{X: 0, Y: 0, TimeMsec: 500, Type: HitObjectCircle},
+			{X: 10, Y: 0, TimeMsec: 750, Type: HitObjectCircle},
+		},
+	}
+	stats := ComputeBeatmapStats(osu)
+	if stats.NumJumps != 2 {
+		t.Errorf("Expected 2 jumps, got %d", stats.NumJumps)
+	}
+	if stats.JumpDensity != 0.5 {
+		t.Errorf("Expected a jump density of 0.5, got %v", stats.JumpDensity)
+	}
+	if stats.Spacing.Max != 400 || stats.Spacing.Min != 10 {
+		t.Errorf("Unexpected spacing %+v", stats.Spacing)
+	}
+}
+
+func TestComputeBeatmapStatsUnsorted(t *testing.T) {
+	osu, err := ReadOsuFile(testOsuFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ComputeBeatmapStats(osu)
+
+	// The same objects out of order give the same stats
+	reversed := make([]HitObject, len(osu.HitObjects))
+	for i, object := range osu.HitObjects {
+		reversed[len(reversed)-1-i] = object
+	}
+	osu.HitObjects = reversed
+	got := ComputeBeatmapStats(osu)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Unsorted objects: got %+v, want %+v", got, want)
+	}
+	if osu.HitObjects[0].TimeMsec != reversed[0].TimeMsec {
+		t.Error("ComputeBeatmapStats reordered the file's objects")
+	}
+}
@@ -0,0 +1,252 @@
+package gosu
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The osu!.cfg and osu!.<user>.cfg settings files. They hold a "Key = Value"
+// setting per line, with comment lines starting with "#". A Cfg keeps every
+// line as it was read, so writing it back only changes the settings which
+// were set.
+
+// A single line of a cfg file
+type cfgLine struct {
+	// The line as read, without the line ending. Empty for new lines.
+	Raw string
+	// Empty for comments, blank lines and anything else which is not a setting
+	Key   string
+	Value string
+}
+
+// A cfg file
+type Cfg struct {
+	lines []cfgLine
+	// The index of the line of each key
+	keys map[string]int
+	// The line ending of the file, osu! writes "\r\n"
+	newline string
+}
+
+// Create an empty cfg
+func NewCfg() *Cfg {
+	return &Cfg{keys: make(map[string]int), newline: "\r\n"}
+}
+
+// Parse a cfg file
+func ParseCfg(r io.Reader) (*Cfg, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cfg := NewCfg()
+	if !bytes.Contains(data, []byte("\r\n")) && bytes.Contains(data, []byte("\n")) {
+		cfg.newline = "\n"
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		raw := strings.TrimSuffix(scanner.Text(), "\r")
+		line := cfgLine{Raw: raw}
+		trimmed := strings.TrimSpace(raw)
+		if i := strings.Index(trimmed, "="); i > 0 && !strings.HasPrefix(trimmed, "#") {
+			line.Key = strings.TrimSpace(trimmed[:i])
+			line.Value = strings.TrimSpace(trimmed[i+1:])
+			// Later lines of the same key win, as in the client
+			cfg.keys[line.Key] = len(cfg.lines)
+		}
+		cfg.lines = append(cfg.lines, line)
+	}
+	return cfg, scanner.Err()
+}
+
+// Read the cfg file at the path
+func ReadCfg(path string) (*Cfg, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCfg(bytes.NewReader(data))
+}
+
+// Returns the value of the key and whether the cfg has it
+func (this *Cfg) Get(key string) (string, bool) {
+	i, ok := this.keys[key]
+	if !ok {
+		return "", false
+	}
+	return this.lines[i].Value, true
+}
+
+// Set the value of the key. A key the cfg does not have yet is added at
+// the end.
+func (this *Cfg) Set(key string, value string) {
+	if i, ok := this.keys[key]; ok {
+		if this.lines[i].Value != value {
+			this.lines[i] = cfgLine{Key: key, Value: value}
+		}
+		return
+	}
+	this.keys[key] = len(this.lines)
+	this.lines = append(this.lines, cfgLine{Key: key, Value: value})
+}
+
+// Remove the key from the cfg, including any earlier lines of the same key
+func (this *Cfg) Delete(key string) {
+	if _, ok := this.keys[key]; !ok {
+		return
+	}
+	lines := this.lines[:0]
+	this.keys = make(map[string]int)
+	for _, line := range this.lines {
+		if line.Key == key {
+			continue
+		}
+		if line.Key != "" {
+			this.keys[line.Key] = len(lines)
+		}
+		lines = append(lines, line)
+	}
+	this.lines = lines
+}
+
+// Returns the keys of the cfg in the order of the file
+func (this *Cfg) Keys() []string {
+	keys := make([]string, 0, len(this.keys))
+	for key := range this.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return this.keys[keys[i]] < this.keys[keys[j]] })
+	return keys
+}
+
+// Write the cfg. Lines which were not changed are written as they were read.
+func (this *Cfg) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	for _, line := range this.lines {
+		if line.Raw != "" || line.Key == "" {
+			buf.WriteString(line.Raw)
+		} else {
+			buf.WriteString(line.Key + " = " + line.Value)
+		}
+		buf.WriteString(this.newline)
+	}
+	return buf.WriteTo(w)
+}
+
+// Save the cfg to the path like SaveFileWithOptions saves a DB. The file is
+// replaced atomically and backed up, and saving is refused while an osu!
+// client is running unless forced, since the client writes its settings
+// back when it exits.
+func (this *Cfg) Save(path string, options SaveOptions) error {
+	if !options.Force {
+		if err := checkClientNotRunning(path); err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := this.WriteTo(&buf); err != nil {
+		return err
+	}
+	return replaceFile(path, buf.Bytes(), options, nil)
+}
+
+// The settings of osu!.<user>.cfg which have a known type. Each field is
+// stored under the key of its name, and booleans are stored as 1 and 0.
+// Keys not listed here are still read and written by Cfg.Get and Cfg.Set.
+type CfgSettings struct {
+	Username         string
+	Language         string
+	Skin             string
+	BeatmapDirectory string
+	VolumeUniversal  int
+	VolumeEffect     int
+	VolumeMusic      int
+	// The universal offset in milliseconds
+	Offset             int
+	DimLevel           int
+	MouseSpeed         float64
+	Fullscreen         bool
+	Width              int
+	Height             int
+	FrameSync          string
+	ShowInterface      bool
+	ScoreboardVisible  bool
+	KeyOsuLeft         string
+	KeyOsuRight        string
+	KeyTaikoInnerLeft  string
+	KeyTaikoInnerRight string
+	KeyTaikoOuterLeft  string
+	KeyTaikoOuterRight string
+	KeyPause           string
+	KeySkip            string
+	LastVersion        string
+}
+
+// Returns the known settings of the cfg. Settings the cfg does not have
+// are left zero.
+func (this *Cfg) Settings() (CfgSettings, error) {
+	var settings CfgSettings
+	val := reflect.ValueOf(&settings).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		key := val.Type().Field(i).Name
+		value, ok := this.Get(key)
+		if !ok {
+			continue
+		}
+		field := val.Field(i)
+		var err error
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Int:
+			var n int64
+			n, err = strconv.ParseInt(value, 10, 64)
+			field.SetInt(n)
+		case reflect.Float64:
+			var f float64
+			f, err = strconv.ParseFloat(value, 64)
+			field.SetFloat(f)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(value)
+			field.SetBool(b)
+		}
+		if err != nil {
+			return settings, fmt.Errorf("Invalid value %q of %s: %v", value, key, err)
+		}
+	}
+	return settings, nil
+}
+
+// Set the known settings in the cfg. A setting the cfg does not have is
+// only added if its value is not zero.
+func (this *Cfg) SetSettings(settings CfgSettings) {
+	val := reflect.ValueOf(settings)
+	for i := 0; i < val.NumField(); i++ {
+		key := val.Type().Field(i).Name
+		field := val.Field(i)
+		if _, ok := this.Get(key); !ok && field.Interface() == reflect.Zero(field.Type()).Interface() {
+			continue
+		}
+		var value string
+		switch field.Kind() {
+		case reflect.Float64:
+			value = strconv.FormatFloat(field.Float(), 'f', -1, 64)
+		case reflect.Bool:
+			value = "0"
+			if field.Bool() {
+				value = "1"
+			}
+		default:
+			value = fmt.Sprint(field.Interface())
+		}
+		this.Set(key, value)
+	}
+}
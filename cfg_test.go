@@ -0,0 +1,101 @@
+package gosu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const testCfg = "# osu! configuration for stymphalian\r\n" +
+	"# last updated on Friday, February 9, 2018\r\n" +
+	"\r\n" +
+	"BeatmapDirectory = Songs\r\n" +
+	"VolumeUniversal = 80\r\n" +
+	"Offset = -12\r\n" +
+	"MouseSpeed = 1.25\r\n" +
+	"Fullscreen = 1\r\n" +
+	"KeyOsuLeft = Z\r\n" +
+	"SomeFutureSetting=  keep me  \r\n"
+
+func TestCfg(t *testing.T) {
+	cfg, err := ParseCfg(strings.NewReader(testCfg))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if _, err := cfg.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != testCfg {
+		t.Errorf("The cfg changed when written back:\n%q", out.String())
+	}
+	if value, ok := cfg.Get("SomeFutureSetting"); !ok || value != "keep me" {
+		t.Errorf("Expected the unknown setting, got %q %v", value, ok)
+	}
+
+	settings, err := cfg.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.BeatmapDirectory != "Songs" || settings.VolumeUniversal != 80 ||
+		settings.Offset != -12 || settings.MouseSpeed != 1.25 || !settings.Fullscreen ||
+		settings.KeyOsuLeft != "Z" || settings.Skin != "" {
+		t.Errorf("Unexpected settings %+v", settings)
+	}
+
+	settings.VolumeUniversal = 100
+	settings.Fullscreen = false
+	settings.Skin = "- Custom Skin -"
+	cfg.SetSettings(settings)
+	cfg.Delete("KeyOsuLeft")
+	out.Reset()
+	if _, err := cfg.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(testCfg, "VolumeUniversal = 80", "VolumeUniversal = 100", 1)
+	expected = strings.Replace(expected, "Fullscreen = 1", "Fullscreen = 0", 1)
+	expected = strings.Replace(expected, "KeyOsuLeft = Z\r\n", "", 1)
+	expected += "Skin = - Custom Skin -\r\n"
+	if out.String() != expected {
+		t.Errorf("Expected\n%q\ngot\n%q", expected, out.String())
+	}
+	keys := cfg.Keys()
+	if len(keys) != 7 || keys[0] != "BeatmapDirectory" || keys[6] != "Skin" {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+
+	cfg.Set("Offset", "soon")
+	if _, err := cfg.Settings(); err == nil {
+		t.Error("Expected an invalid offset to fail")
+	}
+}
+
+func TestCfgDuplicateKey(t *testing.T) {
+	cfg, err := ParseCfg(strings.NewReader("Skin = Old\nVolumeMusic = 40\nSkin = New\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skin, _ := cfg.Get("Skin"); skin != "New" {
+		t.Errorf("Expected the last Skin to win, got %q", skin)
+	}
+
+	// Deleting the key removes every line of it
+	cfg.Delete("Skin")
+	var out bytes.Buffer
+	if _, err := cfg.WriteTo(&out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "VolumeMusic = 40\n" {
+		t.Errorf("Unexpected cfg after the delete %q", out.String())
+	}
+	reread, err := ParseCfg(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reread.Get("Skin"); ok {
+		t.Error("The deleted key came back")
+	}
+	if volume, ok := reread.Get("VolumeMusic"); !ok || volume != "40" {
+		t.Errorf("Expected the other key to stay, got %q %v", volume, ok)
+	}
+}
@@ -0,0 +1,71 @@
+package gosu
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Detection of a running osu! client. The client keeps its DBs in memory and
+// writes them back when it exits, so any change saved while it runs is lost.
+
+// The DB files the client writes back on exit, besides its cfg files
+var clientDbNames = map[string]bool{
+	"osu!.db":       true,
+	"collection.db": true,
+	"scores.db":     true,
+	"presence.db":   true,
+}
+
+// The name of the client's executable
+const clientExecutable = "osu!.exe"
+
+// A running osu! client
+type ClientProcess struct {
+	Pid int
+	// The path of the executable as the process was started with. Under Wine
+	// this is usually a windows style path.
+	Path string
+}
+
+// Returned when saving a DB file while an osu! client is running
+type ClientRunningError struct {
+	Path    string
+	Clients []ClientProcess
+}
+
+func (this ClientRunningError) Error() string {
+	pids := make([]string, len(this.Clients))
+	for i, client := range this.Clients {
+		pids[i] = fmt.Sprint(client.Pid)
+	}
+	return fmt.Sprintf("osu! is running (pid %s) and would overwrite %s when it exits. "+
+		"Close osu! first or force the write.", strings.Join(pids, ", "), this.Path)
+}
+
+// Returns a ClientRunningError if the file at the path is a DB or cfg file
+// the client writes back on exit and a client is running.
+func checkClientNotRunning(path string) error {
+	name := strings.ToLower(filepath.Base(path))
+	isCfg := strings.HasPrefix(name, "osu!.") && strings.HasSuffix(name, ".cfg")
+	if !clientDbNames[name] && !isCfg {
+		return nil
+	}
+	clients, err := RunningClients()
+	if err != nil {
+		return fmt.Errorf("Failed to check whether osu! is running: %v", err)
+	}
+	if len(clients) > 0 {
+		return ClientRunningError{path, clients}
+	}
+	return nil
+}
+
+// Returns whether the path of an executable, unix or windows style, is of
+// the osu! client.
+func isClientExecutable(path string) bool {
+	if i := strings.LastIndexAny(path, `/\`); i >= 0 {
+		path = path[i+1:]
+	}
+	return strings.EqualFold(path, clientExecutable)
+}
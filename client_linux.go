@@ -0,0 +1,47 @@
+package gosu
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Returns the running osu! clients. On Linux the client runs under Wine,
+// which shows up in /proc as a process named osu!.exe or as the wine loader
+// with the path of osu!.exe as its first argument.
+func RunningClients() ([]ClientProcess, error) {
+	return findClients("/proc")
+}
+
+func findClients(procDir string) ([]ClientProcess, error) {
+	entries, err := ioutil.ReadDir(procDir)
+	if err != nil {
+		return nil, err
+	}
+	var clients []ClientProcess
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		// Processes can exit while they are looked at, so errors are ignored
+		cmdline, _ := ioutil.ReadFile(filepath.Join(procDir, entry.Name(), "cmdline"))
+		args := strings.Split(string(bytes.TrimRight(cmdline, "\x00")), "\x00")
+		if isClientExecutable(args[0]) {
+			clients = append(clients, ClientProcess{pid, args[0]})
+			continue
+		}
+		if len(args) > 1 && strings.HasPrefix(filepath.Base(args[0]), "wine") &&
+			isClientExecutable(args[1]) {
+			clients = append(clients, ClientProcess{pid, args[1]})
+			continue
+		}
+		comm, _ := ioutil.ReadFile(filepath.Join(procDir, entry.Name(), "comm"))
+		if isClientExecutable(strings.TrimSpace(string(comm))) {
+			clients = append(clients, ClientProcess{pid, args[0]})
+		}
+	}
+	return clients, nil
+}
@@ -0,0 +1,55 @@
+package gosu
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindClients(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	processes := []struct {
+		Pid     string
+		Cmdline string
+		Comm    string
+	}{
+		{"1", "/sbin/init\x00", "systemd\n"},
+		{"20", "C:\\Program Files\\osu!\\osu!.exe\x00", "osu!.exe\n"},
+		{"31", "/usr/bin/wine64-preloader\x00/home/user/.wine/drive_c/osu!/OSU!.EXE\x00", "wine64-preload\n"},
+		{"42", "/usr/bin/vim\x00osu!.exe\x00notes\x00", "vim\n"},
+		{"50", "/usr/bin/wine-preloader\x00C:\\osu!\\launcher.exe\x00", "osu!.exe\n"},
+		{"self", "", ""},
+	}
+	for _, process := range processes {
+		processDir := filepath.Join(dir, process.Pid)
+		if err := os.Mkdir(processDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		ioutil.WriteFile(filepath.Join(processDir, "cmdline"), []byte(process.Cmdline), 0644)
+		ioutil.WriteFile(filepath.Join(processDir, "comm"), []byte(process.Comm), 0644)
+	}
+
+	clients, err := findClients(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []ClientProcess{
+		{20, "C:\\Program Files\\osu!\\osu!.exe"},
+		{31, "/home/user/.wine/drive_c/osu!/OSU!.EXE"},
+		{50, "/usr/bin/wine-preloader"},
+	}
+	if len(clients) != len(expected) {
+		t.Fatalf("Expected clients %v, got %v", expected, clients)
+	}
+	for i := range expected {
+		if clients[i] != expected[i] {
+			t.Errorf("Expected client %v, got %v", expected[i], clients[i])
+		}
+	}
+}
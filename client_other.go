@@ -0,0 +1,72 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package gosu
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Returns the running osu! clients, as listed by ps. The client runs under
+// Wine, with the path of osu!.exe as the command or as the first argument of
+// the wine loader.
+func RunningClients() ([]ClientProcess, error) {
+	out, err := exec.Command("ps", "-axo", "pid=,args=").Output()
+	if err != nil {
+		return nil, err
+	}
+	var clients []ClientProcess
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		if path, ok := clientOfArgs(fields[1:]); ok {
+			clients = append(clients, ClientProcess{pid, path})
+		}
+	}
+	return clients, scanner.Err()
+}
+
+// Returns the path of osu!.exe if the process with the args runs it, either
+// as the command or as the first argument of a wine loader.
+func clientOfArgs(args []string) (string, bool) {
+	if path, ok := leadingClientPath(args); ok {
+		return path, true
+	}
+	if len(args) > 1 && strings.HasPrefix(filepath.Base(args[0]), "wine") {
+		return leadingClientPath(args[1:])
+	}
+	return "", false
+}
+
+// Returns the path the leading args make up if it is osu!.exe. ps splits
+// paths with spaces, so the args are joined up until the next one starts a
+// new path.
+func leadingClientPath(args []string) (string, bool) {
+	path := args[0]
+	for i := 1; ; i++ {
+		if isClientExecutable(path) {
+			return path, true
+		}
+		if i == len(args) || startsPath(args[i]) {
+			return "", false
+		}
+		path += " " + args[i]
+	}
+}
+
+// Returns whether the arg is the start of an absolute or windows path
+func startsPath(arg string) bool {
+	return strings.HasPrefix(arg, "/") || (len(arg) >= 2 && arg[1] == ':')
+}
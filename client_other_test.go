@@ -0,0 +1,28 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package gosu
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientOfArgs(t *testing.T) {
+	for _, test := range []struct {
+		Args string
+		Path string
+	}{
+		{`/sbin/launchd`, ""},
+		{`C:\Program Files\osu!\osu!.exe`, `C:\Program Files\osu!\osu!.exe`},
+		{`/usr/local/bin/wine64-preloader C:\osu!\OSU!.EXE -devserver`, `C:\osu!\OSU!.EXE`},
+		{`/usr/bin/vim osu!.exe notes`, ""},
+		{`/usr/bin/less /Users/me/osu!/osu!.exe`, ""},
+		{`/usr/local/bin/wine C:\osu!\launcher.exe osu!.exe`, ""},
+	} {
+		path, ok := clientOfArgs(strings.Fields(test.Args))
+		if path != test.Path || ok != (test.Path != "") {
+			t.Errorf("%q: got %q, %v, want %q", test.Args, path, ok, test.Path)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package gosu
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Returns the running osu! clients, as listed by tasklist
+func RunningClients() ([]ClientProcess, error) {
+	out, err := exec.Command("tasklist", "/FO", "CSV", "/NH",
+		"/FI", "IMAGENAME eq "+clientExecutable).Output()
+	if err != nil {
+		return nil, err
+	}
+	// Without a match tasklist prints an informational line instead
+	if strings.HasPrefix(strings.TrimSpace(string(out)), "INFO:") {
+		return nil, nil
+	}
+	records, err := csv.NewReader(strings.NewReader(string(out))).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Unexpected output of tasklist: %v", err)
+	}
+	var clients []ClientProcess
+	for _, record := range records {
+		if len(record) < 2 || !isClientExecutable(record[0]) {
+			continue
+		}
+		pid, err := strconv.Atoi(record[1])
+		if err != nil {
+			continue
+		}
+		clients = append(clients, ClientProcess{pid, record[0]})
+	}
+	return clients, nil
+}
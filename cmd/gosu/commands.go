@@ -0,0 +1,484 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Stymphalian/gosu"
+	"github.com/kr/pretty"
+)
+
+func init() {
+	addCommand(&command{"info", "<file>", "Show the version and entry counts of a file", runInfo})
+	addCommand(&command{"dump", "<file>", "Print the whole file as JSON or Go values", runDump})
+	addCommand(&command{"ls", "<file>", "List the beatmaps, scores, collections or players", runLs})
+	addCommand(&command{"get", "<file> <key>", "Print a single entry", runGet})
+	addCommand(&command{"set", "<file> <key> <field> <value>", "Change a field of an entry", runSet})
+	addCommand(&command{"convert", "<in> <out>", "Convert between binary and JSON or between versions", runConvert})
+	addCommand(&command{"validate", "<file>", "Check the file for invalid values", runValidate})
+	addCommand(&command{"hexdump", "<file>", "Print the offset, bytes and value of every field", runHexDump})
+	addCommand(&command{"schema", "<type>", "Print the format of a file type as JSON or Kaitai Struct", runSchema})
+	addCommand(&command{"diff", "<old> <new>", "Show the changes between two files of the same type", runDiff})
+	addCommand(&command{"restore", "<file>", "Roll the file back to a backup made when it was written", runRestore})
+}
+
+func runInfo(flags *flag.FlagSet, args []string, out io.Writer) error {
+	fileType := flags.String("type", "", typeUsage)
+	args, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	f, err := readDbFile(args[0], *fileType)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 1, ' ', 0)
+	fmt.Fprintf(w, "File:\t%s\n", args[0])
+	fmt.Fprintf(w, "Type:\t%s\n", f.Type)
+	fmt.Fprintf(w, "Version:\t%d\n", f.Version)
+	switch db := f.Db.(type) {
+	case *gosu.OsuDb:
+		fmt.Fprintf(w, "Player:\t%s\n", db.PlayerName.Text)
+		fmt.Fprintf(w, "Folders:\t%d\n", db.FolderCount)
+		fmt.Fprintf(w, "Beatmaps:\t%d\n", db.NumBeatmaps)
+	case *gosu.ScoresDb:
+		numScores := 0
+		for i := range db.Beatmaps {
+			numScores += int(db.Beatmaps[i].NumScores)
+		}
+		fmt.Fprintf(w, "Beatmaps:\t%d\n", db.NumBeatmaps)
+		fmt.Fprintf(w, "Scores:\t%d\n", numScores)
+	case *gosu.CollectionDb:
+		numBeatmaps := 0
+		for i := range db.Collections {
+			numBeatmaps += int(db.Collections[i].NumBeatmapMd5Hashes)
+		}
+		fmt.Fprintf(w, "Collections:\t%d\n", db.NumCollections)
+		fmt.Fprintf(w, "Beatmaps:\t%d\n", numBeatmaps)
+	case *gosu.PresenceDb:
+		fmt.Fprintf(w, "Players:\t%d\n", db.NumPlayers)
+	case *gosu.Replay:
+		fmt.Fprintf(w, "Mode:\t%s\n", gosu.GameMode(db.GameplayMode))
+		fmt.Fprintf(w, "Player:\t%s\n", db.PlayerName.Text)
+		fmt.Fprintf(w, "Beatmap:\t%s\n", db.BeatmapMd5Hash.Text)
+		fmt.Fprintf(w, "Score:\t%d\n", db.ReplayScore)
+		fmt.Fprintf(w, "Mods:\t%s\n", gosu.Mods(db.Mods))
+		fmt.Fprintf(w, "Played:\t%s\n", gosu.TicksToTime(uint64(db.TimestampTicks)))
+	}
+	return w.Flush()
+}
+
+func runDump(flags *flag.FlagSet, args []string, out io.Writer) error {
+	fileType := flags.String("type", "", typeUsage)
+	format := flags.String("format", formatJSON, "The output format: json or pretty")
+	args, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	f, err := readDbFile(args[0], *fileType)
+	if err != nil {
+		return err
+	}
+	return printValue(out, f.Db, *format)
+}
+
+func runLs(flags *flag.FlagSet, args []string, out io.Writer) error {
+	fileType := flags.String("type", "", typeUsage)
+	query := flags.String("q", "", "Only list entries containing this text (case insensitive)")
+	mode := flags.String("mode", "", "Only list beatmaps or scores of this mode: osu, taiko, catch or mania")
+	player := flags.String("player", "", "Only list scores of this player")
+	limit := flags.Int("n", 0, "List at most this many entries")
+	args, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	f, err := readDbFile(args[0], *fileType)
+	if err != nil {
+		return err
+	}
+	wantMode := -1
+	if *mode != "" {
+		m, err := parseMode(*mode)
+		if err != nil {
+			return err
+		}
+		wantMode = int(m)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	count := 0
+	emit := func(entryMode int, entryPlayer string, columns ...interface{}) {
+		if *limit > 0 && count >= *limit {
+			return
+		}
+		if wantMode >= 0 && entryMode != wantMode {
+			return
+		}
+		if *player != "" && !strings.EqualFold(entryPlayer, *player) {
+			return
+		}
+		fields := make([]string, len(columns))
+		for i, column := range columns {
+			fields[i] = fmt.Sprint(column)
+		}
+		line := strings.Join(fields, "\t") + "\n"
+		if *query != "" && !strings.Contains(strings.ToLower(line), strings.ToLower(*query)) {
+			return
+		}
+		io.WriteString(w, line)
+		count++
+	}
+
+	switch db := f.Db.(type) {
+	case *gosu.OsuDb:
+		for i := range db.Beatmaps {
+			b := &db.Beatmaps[i]
+			emit(int(b.OsuGameplayMode), "", b.Md5.Text, gosu.GameMode(b.OsuGameplayMode),
+				beatmapName(b))
+		}
+	case *gosu.ScoresDb:
+		for i := range db.Beatmaps {
+			for j := range db.Beatmaps[i].Scores {
+				s := &db.Beatmaps[i].Scores[j]
+				emit(int(s.GameplayMode), s.PlayerName.Text, s.Md5Hash.Text, quote(s.PlayerName.Text),
+					s.ReplayScore, gosu.Mods(s.Mods), s.Grade(),
+					fmt.Sprintf("%.2f%%", s.Accuracy()*100),
+					gosu.TicksToTime(uint64(s.TimestampOfReplayWindowTicks)).Format("2006-01-02"))
+			}
+		}
+	case *gosu.CollectionDb:
+		for i := range db.Collections {
+			c := &db.Collections[i]
+			emit(wantMode, "", quote(c.Name.Text), c.NumBeatmapMd5Hashes)
+		}
+	case *gosu.PresenceDb:
+		for i := range db.Players {
+			p := &db.Players[i]
+			emit(wantMode, p.PlayerName.Text, p.PlayerId, quote(p.PlayerName.Text),
+				p.CountryCode(), p.GlobalRank)
+		}
+	default:
+		return fmt.Errorf("Can not list the entries of a %s file", f.Type)
+	}
+	return w.Flush()
+}
+
+func runGet(flags *flag.FlagSet, args []string, out io.Writer) error {
+	fileType := flags.String("type", "", typeUsage)
+	format := flags.String("format", formatPretty, "The output format: json or pretty")
+	args, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	f, err := readDbFile(args[0], *fileType)
+	if err != nil {
+		return err
+	}
+	path, err := findEntry(f, args[1])
+	if err != nil {
+		return err
+	}
+	entry, err := gosu.FieldByPath(f.Db, path)
+	if err != nil {
+		return err
+	}
+	return printValue(out, entry.Addr().Interface(), *format)
+}
+
+func runSet(flags *flag.FlagSet, args []string, out io.Writer) error {
+	fileType := flags.String("type", "", typeUsage)
+	output := flags.String("o", "", "Write the changed file here instead of overwriting it")
+	force := flags.Bool("force", false, forceUsage)
+	args, err := parseArgs(flags, args, 4)
+	if err != nil {
+		return err
+	}
+	f, err := readDbFile(args[0], *fileType)
+	if err != nil {
+		return err
+	}
+	path, err := findEntry(f, args[1])
+	if err != nil {
+		return err
+	}
+	if path != "" {
+		path += "."
+	}
+	if err := gosu.SetFieldByPath(f.Db, path+args[2], args[3]); err != nil {
+		return err
+	}
+
+	outPath := *output
+	if outPath == "" {
+		outPath = args[0]
+	}
+	return f.write(outPath, f.Format, f.Version, *force)
+}
+
+func runConvert(flags *flag.FlagSet, args []string, out io.Writer) error {
+	fileType := flags.String("type", "", typeUsage)
+	format := flags.String("format", "", "The output format: binary or json. "+
+		"Guessed from the output file name when empty.")
+	version := flags.Uint("version", 0, "The version to write binary files with. "+
+		"Defaults to the version of the input. Fields which do not exist in the "+
+		"input's version are left zero.")
+	force := flags.Bool("force", false, forceUsage)
+	args, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	if *fileType == "" {
+		*fileType = guessType(args[0])
+		if *fileType == "" {
+			*fileType = guessType(args[1])
+		}
+	}
+	f, err := readDbFile(args[0], *fileType)
+	if err != nil {
+		return err
+	}
+	if *format == "" {
+		*format = guessFormat(args[1])
+	}
+	if *version != 0 {
+		f.Version = gosu.Int(*version)
+	}
+	return f.write(args[1], *format, f.Version, *force)
+}
+
+func runValidate(flags *flag.FlagSet, args []string, out io.Writer) error {
+	fileType := flags.String("type", "", typeUsage)
+	args, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	f, err := readDbFile(args[0], *fileType)
+	if err != nil {
+		return err
+	}
+	err = f.Db.(gosu.Validator).Validate()
+	if errs, ok := err.(gosu.ValidationErrors); ok {
+		for _, e := range errs {
+			fmt.Fprintln(out, e)
+		}
+		return fmt.Errorf("Found %d invalid values", len(errs))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%s is valid\n", args[0])
+	return nil
+}
+
+func runDiff(flags *flag.FlagSet, args []string, out io.Writer) error {
+	fileType := flags.String("type", "", typeUsage)
+	args, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+	if *fileType == "" {
+		*fileType = guessType(args[0])
+		if *fileType == "" {
+			*fileType = guessType(args[1])
+		}
+	}
+	oldFile, err := readDbFile(args[0], *fileType)
+	if err != nil {
+		return err
+	}
+	newFile, err := readDbFile(args[1], *fileType)
+	if err != nil {
+		return err
+	}
+	diff, err := gosu.DiffDb(oldFile.Db, newFile.Db)
+	if err != nil {
+		return err
+	}
+	return diff.WriteChangelog(out)
+}
+
+func runHexDump(flags *flag.FlagSet, args []string, out io.Writer) error {
+	fileType := flags.String("type", "", typeUsage)
+	version := flags.Uint("version", 0, "Decode the file as this version instead of the "+
+		"version stored in it")
+	args, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *fileType == "" {
+		*fileType = guessType(args[0])
+		if *fileType == "" {
+			return fmt.Errorf("Can not tell the type of %s, use -type", args[0])
+		}
+	}
+	db, err := newDb(*fileType)
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	fileVersion := gosu.Int(*version)
+	if fileVersion == 0 {
+		fileVersion, err = versionOf(*fileType, data)
+		if err != nil {
+			return err
+		}
+	}
+	return gosu.HexDump(db, bytes.NewReader(data), fileVersion, out)
+}
+
+func runSchema(flags *flag.FlagSet, args []string, out io.Writer) error {
+	format := flags.String("format", formatJSON, "The output format: json or ksy")
+	args, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	db, err := newDb(args[0])
+	if err != nil {
+		return err
+	}
+	schema, err := gosu.SchemaOf(db)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case formatJSON:
+		return schema.WriteJSON(out)
+	case "ksy":
+		extension := "db"
+		if args[0] == typeReplay {
+			extension = "osr"
+		}
+		return schema.WriteKaitai(out, extension)
+	}
+	return errors.New("Unknown format " + *format)
+}
+
+// Returns the path of the entry identified by the key. The key is
+//
+//	osu!.db: the beatmap's MD5
+//	scores.db: the beatmap's MD5, a replay MD5 or an online score id
+//	collection.db: the collection's name
+//	presence.db: the player's id or name
+//
+// "-" returns the empty path of the whole file.
+func findEntry(f *dbFile, key string) (string, error) {
+	if key == "-" {
+		return "", nil
+	}
+	id, idErr := strconv.ParseUint(key, 10, 64)
+	switch db := f.Db.(type) {
+	case *gosu.OsuDb:
+		for i := range db.Beatmaps {
+			if strings.EqualFold(db.Beatmaps[i].Md5.Text, key) {
+				return fmt.Sprintf("Beatmaps[%d]", i), nil
+			}
+		}
+	case *gosu.ScoresDb:
+		for i := range db.Beatmaps {
+			if strings.EqualFold(db.Beatmaps[i].Md5Hash.Text, key) {
+				return fmt.Sprintf("Beatmaps[%d]", i), nil
+			}
+			for j := range db.Beatmaps[i].Scores {
+				s := &db.Beatmaps[i].Scores[j]
+				if strings.EqualFold(s.ReplayMd5Hash.Text, key) ||
+					(idErr == nil && id != 0 && uint64(s.OnlineScoreId) == id) {
+					return fmt.Sprintf("Beatmaps[%d].Scores[%d]", i, j), nil
+				}
+			}
+		}
+	case *gosu.CollectionDb:
+		for i := range db.Collections {
+			if db.Collections[i].Name.Text == key {
+				return fmt.Sprintf("Collections[%d]", i), nil
+			}
+		}
+	case *gosu.PresenceDb:
+		for i := range db.Players {
+			p := &db.Players[i]
+			if (idErr == nil && uint64(p.PlayerId) == id) ||
+				strings.EqualFold(p.PlayerName.Text, key) {
+				return fmt.Sprintf("Players[%d]", i), nil
+			}
+		}
+	default:
+		return "", fmt.Errorf("A %s file only has the entry \"-\"", f.Type)
+	}
+	return "", fmt.Errorf("No entry %q in %s", key, f.Type)
+}
+
+func printValue(out io.Writer, value interface{}, format string) error {
+	switch format {
+	case formatJSON:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	case formatPretty:
+		_, err := fmt.Fprintf(out, "%# v\n", pretty.Formatter(value))
+		return err
+	}
+	return errors.New("Unknown format " + format)
+}
+
+// Parse a game mode from its name or number
+func parseMode(name string) (gosu.GameMode, error) {
+	switch strings.ToLower(name) {
+	case "0", "osu", "osu!", "std", "standard":
+		return gosu.ModeStandard, nil
+	case "1", "taiko", "osu!taiko":
+		return gosu.ModeTaiko, nil
+	case "2", "catch", "ctb", "fruits", "osu!catch":
+		return gosu.ModeCatch, nil
+	case "3", "mania", "osu!mania":
+		return gosu.ModeMania, nil
+	}
+	return 0, fmt.Errorf("Unknown mode %q", name)
+}
+
+func beatmapName(b *gosu.BeatMap) string {
+	return fmt.Sprintf("%s - %s [%s] (%s)", b.ArtistName.Text, b.SongTitle.Text,
+		b.Difficulty.Text, b.CreatorName.Text)
+}
+
+// Quote text which may contain spaces, so that every column is a single word
+func quote(text string) string {
+	if strings.ContainsAny(text, " \t") {
+		return strconv.Quote(text)
+	}
+	return text
+}
+
+func runRestore(flags *flag.FlagSet, args []string, out io.Writer) error {
+	list := flags.Bool("list", false, "List the backups, newest first, instead of restoring one")
+	backup := flags.String("backup", "", "The backup to restore. Defaults to the newest.")
+	force := flags.Bool("force", false, forceUsage)
+	args, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *list {
+		backups, err := gosu.Backups(args[0])
+		if err != nil {
+			return err
+		}
+		for _, backup := range backups {
+			fmt.Fprintln(out, backup)
+		}
+		return nil
+	}
+	options := gosu.DefaultSaveOptions
+	options.Force = *force
+	return gosu.RestoreWithOptions(args[0], *backup, options)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Stymphalian/gosu"
+)
+
+// The types of files the tool understands
+const (
+	typeOsu        = "osu"
+	typeScores     = "scores"
+	typeCollection = "collection"
+	typePresence   = "presence"
+	typeReplay     = "replay"
+)
+
+// The formats a file can be read and written in
+const (
+	formatBinary = "binary"
+	formatJSON   = "json"
+	formatPretty = "pretty"
+)
+
+const typeUsage = "The type of the file: osu, scores, collection, presence or replay. " +
+	"Guessed from the file name when empty."
+
+const forceUsage = "Write the file even though osu! is running and would overwrite it on exit"
+
+// A decoded DB file
+type dbFile struct {
+	Type    string
+	Format  string
+	Version gosu.Int
+	Db      gosu.BinaryOsuCodec
+}
+
+// Returns an empty DB of the given type
+func newDb(fileType string) (gosu.BinaryOsuCodec, error) {
+	switch fileType {
+	case typeOsu:
+		return new(gosu.OsuDb), nil
+	case typeScores:
+		return new(gosu.ScoresDb), nil
+	case typeCollection:
+		return new(gosu.CollectionDb), nil
+	case typePresence:
+		return new(gosu.PresenceDb), nil
+	case typeReplay:
+		return new(gosu.Replay), nil
+	}
+	return nil, fmt.Errorf("Unknown file type %q", fileType)
+}
+
+// Guess the type of the file from its name, or return an empty string
+func guessType(path string) string {
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(path), ".json"))
+	switch {
+	case name == "osu!.db":
+		return typeOsu
+	case name == "scores.db":
+		return typeScores
+	case name == "collection.db":
+		return typeCollection
+	case name == "presence.db":
+		return typePresence
+	case strings.HasSuffix(name, ".osr"):
+		return typeReplay
+	}
+	return ""
+}
+
+// Guess the format of the file from its name
+func guessFormat(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return formatJSON
+	}
+	return formatBinary
+}
+
+// Read the file, which is either in the binary format written by the client
+// or in the JSON format written by "gosu dump" and "gosu convert".
+// Args:
+//   path: The file to read
+//   fileType: The type of the file, or empty to guess it from the name
+func readDbFile(path string, fileType string) (*dbFile, error) {
+	if fileType == "" {
+		fileType = guessType(path)
+		if fileType == "" {
+			return nil, fmt.Errorf("Can not tell the type of %s, use -type", path)
+		}
+	}
+	db, err := newDb(fileType)
+	if err != nil {
+		return nil, err
+	}
+	f := &dbFile{Type: fileType, Format: guessFormat(path), Db: db}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if f.Format == formatJSON {
+		if err := json.NewDecoder(file).Decode(db); err != nil {
+			return nil, fmt.Errorf("Failed to decode %s: %v", path, err)
+		}
+		version, err := gosu.FieldByPath(db, "Version")
+		if err != nil {
+			return nil, err
+		}
+		f.Version = gosu.Int(version.Uint())
+		return f, nil
+	}
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	f.Version, err = versionOf(fileType, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := gosu.NewDecoder(data, f.Version).Decode(db); err != nil {
+		return nil, fmt.Errorf("Failed to decode %s: %v", path, err)
+	}
+	return f, nil
+}
+
+// Returns the version stored in the binary file
+func versionOf(fileType string, data []byte) (gosu.Int, error) {
+	if fileType == typeReplay {
+		return gosu.GetVersionOfReplay(bytes.NewReader(data))
+	}
+	return gosu.GetVersionOfBinary(bytes.NewReader(data))
+}
+
+// Write the DB to the path in the given format. Binary files are written
+// with the given version and must pass validation. They are saved with
+// gosu.SaveFile, which keeps backups of the file they replace and refuses to
+// write the client's DBs while it runs unless forced.
+func (this *dbFile) write(path string, format string, version gosu.Int, force bool) error {
+	if format == formatBinary {
+		if err := this.prepareBinary(version); err != nil {
+			return err
+		}
+		options := gosu.DefaultSaveOptions
+		options.Force = force
+		return gosu.SaveFileWithOptions(path, this.Db, version, options)
+	}
+	var buf bytes.Buffer
+	if err := this.encode(&buf, format, version); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func (this *dbFile) encode(w io.Writer, format string, version gosu.Int) error {
+	switch format {
+	case formatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(this.Db)
+	case formatBinary:
+		if err := this.prepareBinary(version); err != nil {
+			return err
+		}
+		return gosu.MarshalValidated(this.Db, w, version)
+	}
+	return errors.New("Unknown format " + format)
+}
+
+// Update the fields which depend on the version before writing the DB as
+// a binary of that version.
+func (this *dbFile) prepareBinary(version gosu.Int) error {
+	if err := gosu.SetFieldByPath(this.Db, "Version", fmt.Sprint(version)); err != nil {
+		return err
+	}
+	if osu, ok := this.Db.(*gosu.OsuDb); ok {
+		// The size of every beatmap depends on the version and its fields
+		for i := range osu.Beatmaps {
+			if err := osu.Beatmaps[i].UpdateSize(version); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Command gosu inspects and edits osu! DB files from the shell.
+//
+// Usage:
+//   gosu <command> [flags] <file> [args...]
+//
+// Run "gosu help" for the list of commands. The type of a file is guessed
+// from its name (osu!.db, scores.db, collection.db, presence.db or *.osr,
+// optionally followed by .json) and can be overridden with -type.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+type command struct {
+	Name string
+	// The arguments of the command, shown after the flags in the usage
+	Args  string
+	Short string
+	// Parse the flags in the set and run the command
+	Run func(flags *flag.FlagSet, args []string, out io.Writer) error
+}
+
+var commands = map[string]*command{}
+
+func addCommand(c *command) {
+	commands[c.Name] = c
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" {
+		usage(os.Stderr)
+		os.Exit(2)
+	}
+	if err := run(os.Args[1], os.Args[2:], os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "gosu %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// Run the named command with its arguments, writing the output to out
+func run(name string, args []string, out io.Writer) error {
+	c, ok := commands[name]
+	if !ok {
+		usage(os.Stderr)
+		return fmt.Errorf("Unknown command %q", name)
+	}
+	flags := flag.NewFlagSet("gosu "+name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: gosu %s [flags] %s\n%s\n\nFlags:\n",
+			c.Name, c.Args, c.Short)
+		flags.PrintDefaults()
+	}
+	return c.Run(flags, args, out)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gosu <command> [flags] <file> [args...]")
+	fmt.Fprintln(w, "\nCommands:")
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].Short)
+	}
+	fmt.Fprintln(w, "\nRun \"gosu <command> -h\" for the flags of a command.")
+}
+
+// Parse the flags and check that exactly n positional arguments remain
+func parseArgs(flags *flag.FlagSet, args []string, n int) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() != n {
+		flags.Usage()
+		return nil, fmt.Errorf("Expected %d arguments, got %d", n, flags.NArg())
+	}
+	return flags.Args(), nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func runCommand(t *testing.T, args ...string) string {
+	var out bytes.Buffer
+	if err := run(args[0], args[1:], &out); err != nil {
+		t.Fatalf("gosu %s: %v", strings.Join(args, " "), err)
+	}
+	return out.String()
+}
+
+func TestInfoAndLs(t *testing.T) {
+	info := runCommand(t, "info", "../../data/scores.db")
+	if !strings.Contains(info, "Version:  20171227") || !strings.Contains(info, "Beatmaps: 408") {
+		t.Errorf("Unexpected info:\n%s", info)
+	}
+
+	ls := runCommand(t, "ls", "-n", "3", "../../data/osu!.db")
+	if lines := strings.Count(ls, "\n"); lines != 3 {
+		t.Errorf("Expected 3 beatmaps, got %d:\n%s", lines, ls)
+	}
+	if out := runCommand(t, "ls", "-q", "no such beatmap", "../../data/osu!.db"); out != "" {
+		t.Errorf("Expected no beatmaps, got:\n%s", out)
+	}
+}
+
+func TestSetGetConvert(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosu-cmd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Round trip the collections through JSON and back
+	jsonPath := filepath.Join(dir, "collection.db.json")
+	dbPath := filepath.Join(dir, "collection.db")
+	runCommand(t, "convert", "../../data/collection.db", jsonPath)
+	runCommand(t, "convert", jsonPath, dbPath)
+	original, err := ioutil.ReadFile("../../data/collection.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted, err := ioutil.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, converted) {
+		t.Error("The collection.db changed after converting to JSON and back")
+	}
+
+	ls := runCommand(t, "ls", dbPath)
+	name := strings.Trim(strings.Fields(ls)[0], `"`)
+	runCommand(t, "set", dbPath, name, "Name", "renamed by gosu")
+	get := runCommand(t, "get", "-format", "json", dbPath, "renamed by gosu")
+	if !strings.Contains(get, `"Text": "renamed by gosu"`) {
+		t.Errorf("Expected the renamed collection, got:\n%s", get)
+	}
+	runCommand(t, "validate", dbPath)
+
+	var out bytes.Buffer
+	if err := run("set", []string{dbPath, "-", "NumCollections", "0"}, &out); err == nil {
+		t.Error("Expected setting an invalid count to fail validation")
+	}
+
+	// The set made a backup of the converted file, restoring it undoes the set
+	backups := strings.Fields(runCommand(t, "restore", "-list", dbPath))
+	if len(backups) != 1 {
+		t.Fatalf("Expected a single backup, got %v", backups)
+	}
+	runCommand(t, "restore", dbPath)
+	restored, err := ioutil.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(original, restored) {
+		t.Error("Expected the restore to undo the set")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosu-cmd-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	changed := filepath.Join(dir, "presence.db")
+	runCommand(t, "set", "-o", changed, "../../data/presence.db", "BanchoBot", "GlobalRank", "7")
+	diff := runCommand(t, "diff", "../../data/presence.db", changed)
+	expected := "~ player 4294967293 BanchoBot\n    ~ GlobalRank: 0 -> 7\n"
+	if diff != expected {
+		t.Errorf("Expected the changelog\n%s\ngot\n%s", expected, diff)
+	}
+	if diff := runCommand(t, "diff", changed, changed); diff != "No changes\n" {
+		t.Errorf("Expected no changes, got\n%s", diff)
+	}
+}
+
+func TestHexDump(t *testing.T) {
+	dump := runCommand(t, "hexdump", "../../data/collection.db")
+	if !strings.HasPrefix(dump, "0x00000000  ") || !strings.Contains(dump, "Collections[0].Name  String") {
+		t.Errorf("Unexpected dump:\n%s", dump)
+	}
+}
+
+func TestSchema(t *testing.T) {
+	ksy := runCommand(t, "schema", "-format", "ksy", "scores")
+	if !strings.HasPrefix(ksy, "meta:\n  id: scores_db\n") {
+		t.Errorf("Unexpected .ksy:\n%s", ksy)
+	}
+	if json := runCommand(t, "schema", "presence"); !strings.Contains(json, `"root": "PresenceDb"`) {
+		t.Errorf("Unexpected schema:\n%s", json)
+	}
+}
@@ -1,6 +1,6 @@
 
 // Code generated by go generate; DO NOT EDIT.
-// This file was generated by robots at 2018-02-09 01:28:03.893322923 -0800 PST m=+0.000517803
+// This file was generated by robots at 2026-10-19 17:39:43.429822377 +0000 UTC m=+0.011280901
 
 package gosu
 
@@ -73,6 +73,14 @@ func (this *DateTime) MarshalOsuBinary(buf io.Writer, version Int) error {
 	return binary.Write(buf, binary.LittleEndian, this)
 }
 
+func (this *Grade) UnmarshalOsuBinary(buf io.Reader, version Int) error {
+	return binary.Read(buf, binary.LittleEndian, this)
+}
+
+func (this *Grade) MarshalOsuBinary(buf io.Writer, version Int) error {
+	return binary.Write(buf, binary.LittleEndian, this)
+}
+
 func (this *OsuDb) UnmarshalOsuBinary(buf io.Reader, version Int) error {
 	return UnmarshalAny(this, buf, version)
 }
@@ -160,3 +168,27 @@ func (this *PlayerPresence) UnmarshalOsuBinary(buf io.Reader, version Int) error
 func (this *PlayerPresence) MarshalOsuBinary(buf io.Writer, version Int) error {
 	return MarshalAny(this, buf, version)
 }
+
+func (this *OsuDbIndexEntry) UnmarshalOsuBinary(buf io.Reader, version Int) error {
+	return UnmarshalAny(this, buf, version)
+}
+
+func (this *OsuDbIndexEntry) MarshalOsuBinary(buf io.Writer, version Int) error {
+	return MarshalAny(this, buf, version)
+}
+
+func (this *OsuDbIndex) UnmarshalOsuBinary(buf io.Reader, version Int) error {
+	return UnmarshalAny(this, buf, version)
+}
+
+func (this *OsuDbIndex) MarshalOsuBinary(buf io.Writer, version Int) error {
+	return MarshalAny(this, buf, version)
+}
+
+func (this *Replay) UnmarshalOsuBinary(buf io.Reader, version Int) error {
+	return UnmarshalAny(this, buf, version)
+}
+
+func (this *Replay) MarshalOsuBinary(buf io.Writer, version Int) error {
+	return MarshalAny(this, buf, version)
+}
@@ -136,23 +136,10 @@ func UnmarshalAny(db interface{}, buf io.Reader, version Int) error {
 		currentFieldType := dbType.Field(i)
 
 		// handles tags
-		if gotVersion, ok := currentFieldType.Tag.Lookup("osu-end"); ok {
-			intVersion, err := strconv.ParseUint(gotVersion, 10, 32)
-			if err != nil {
-				return err
-			}
-			if version > Int(intVersion) {
-				continue
-			}
-		}
-		if gotVersion, ok := currentFieldType.Tag.Lookup("osu-start"); ok {
-			intVersion, err := strconv.ParseUint(gotVersion, 10, 32)
-			if err != nil {
-				return err
-			}
-			if version < Int(intVersion) {
-				continue
-			}
+		if ok, err := fieldInVersion(currentFieldType, version); err != nil {
+			return err
+		} else if !ok {
+			continue
 		}
 
 		switch dbVal.Field(i).Type().Kind() {
@@ -163,6 +150,16 @@ func UnmarshalAny(db interface{}, buf io.Reader, version Int) error {
 			// when we have a slice of element afterwards
 			intNumElements := int(numElements.(Int))
 
+			// Raw byte arrays, such as the frames of a replay, are read in one go
+			if currentField.Type() == byteSliceType {
+				data := make([]byte, intNumElements)
+				if _, err := io.ReadFull(buf, data); err != nil {
+					return err
+				}
+				currentField.SetBytes(data)
+				continue
+			}
+
 			// Create a new slice of appropriate size and then run the unmarshal
 			// function over each element in the slice.
 			sliceElems := reflect.MakeSlice(currentField.Type(),
@@ -238,6 +235,13 @@ func MarshalAny(db interface{}, buf io.Writer, version Int) error {
 			// when we have a slice of element afterwards
 			intNumElements := int(numElements.(Int))
 
+			if currentField.Type() == byteSliceType {
+				if _, err := buf.Write(currentField.Bytes()[:intNumElements]); err != nil {
+					return err
+				}
+				continue
+			}
+
 			// iterate through each element of the slice and marshal the struct
 			for j := 0; j < intNumElements; j++ {
 				ret := Invoke(currentField.Index(j).Addr().Interface(),
@@ -262,3 +266,30 @@ func MarshalAny(db interface{}, buf io.Writer, version Int) error {
 
 	return nil
 }
+
+// Returns whether the field is stored in the binary format of the given
+// version, according to its osu-start and osu-end tags.
+// Args:
+//   field: The struct field to check
+//   version: The version of the binary being decoded
+func fieldInVersion(field reflect.StructField, version Int) (bool, error) {
+	if gotVersion, ok := field.Tag.Lookup("osu-end"); ok {
+		intVersion, err := strconv.ParseUint(gotVersion, 10, 32)
+		if err != nil {
+			return false, err
+		}
+		if version > Int(intVersion) {
+			return false, nil
+		}
+	}
+	if gotVersion, ok := field.Tag.Lookup("osu-start"); ok {
+		intVersion, err := strconv.ParseUint(gotVersion, 10, 32)
+		if err != nil {
+			return false, err
+		}
+		if version < Int(intVersion) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -3,6 +3,7 @@ package gosu
 import (
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/kr/pretty"
 )
@@ -14,28 +15,103 @@ import (
 // `osu-start:"YYYYMMDD"` - Tells to skip this field if the current osu-version
 //    is less than this version string. This is because this field has not yet
 //    been populated yet.
+// `osu-check:"<kind>"` - Tells Validate which range of values is allowed for
+//    this field. Supported kinds are "md5", "ranked-status", "mode" and
+//    "grade". For slices the check applies to every element.
+//
+// Types marked with a "//gosu:codec" comment get UnmarshalOsuBinary and
+// MarshalOsuBinary methods generated in codec.auto.go (see tools/gen_codec.go),
+// which encode the struct field by field. "//gosu:codec binary" marks fixed
+// size types which are encoded as is.
 
 // Type aliases so that the number of bytes match what osu is expecting.
 // Exceptions:
 // 1. ULEB128 is aliased to uint64 (hopefully this is big enough)
 // 2. String is aliased to a struct with all the required information.
+//
+//gosu:codec binary
 type Byte uint8
+
+//gosu:codec binary
 type Short uint16
+
+//gosu:codec binary
 type Int uint32
+
+//gosu:codec binary
 type Long uint64
 type ULEB128 uint64
+
+//gosu:codec binary
 type Single float32
+
+//gosu:codec binary
 type Double float64
+
+//gosu:codec binary
 type Boolean uint8
 type String struct {
 	Cond Byte
 	Len  ULEB128
 	Text string
 }
+
+//gosu:codec binary
 type DateTime struct {
 	Value uint64
 }
 
+// The game modes supported by osu!. The numeric values match the mode byte
+// stored in the DB files and the "Mode" key of a .osu file.
+type GameMode uint8
+
+const (
+	ModeStandard GameMode = 0
+	ModeTaiko    GameMode = 1
+	ModeCatch    GameMode = 2
+	ModeMania    GameMode = 3
+)
+
+func (this GameMode) String() string {
+	switch this {
+	case ModeStandard:
+		return "osu!"
+	case ModeTaiko:
+		return "osu!taiko"
+	case ModeCatch:
+		return "osu!catch"
+	case ModeMania:
+		return "osu!mania"
+	}
+	return fmt.Sprintf("GameMode(%d)", uint8(this))
+}
+
+// Create a String which holds the given text, filling in the Cond and Len
+// fields the way the osu client expects them.
+func NewString(text string) String {
+	return String{Cond: 0xb, Len: ULEB128(len(text)), Text: text}
+}
+
+// The number of .NET ticks (100ns intervals since 0001-01-01) at the unix
+// epoch. osu! stores most of its timestamps as .NET ticks.
+const ticksAtUnixEpoch = 621355968000000000
+
+// Convert a .NET ticks timestamp into a time.Time
+func TicksToTime(ticks uint64) time.Time {
+	nsec := (int64(ticks) - ticksAtUnixEpoch) * 100
+	return time.Unix(0, nsec).UTC()
+}
+
+// Convert a time.Time into a .NET ticks timestamp
+func TimeToTicks(t time.Time) uint64 {
+	return uint64(t.UnixNano()/100 + ticksAtUnixEpoch)
+}
+
+// Returns the DateTime as a time.Time
+func (this DateTime) Time() time.Time {
+	return TicksToTime(this.Value)
+}
+
 // A helper method for Pretty printing any object
 func PrettyPrint(v interface{}) {
 	fmt.Printf("%# v\n", pretty.Formatter(v))
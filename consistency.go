@@ -0,0 +1,215 @@
+package gosu
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Cross references the osu!.db, scores.db and collection.db of an install and
+// reports the entries which point at beatmaps that no longer exist.
+
+// Scores stored for a beatmap which is not in osu!.db
+type OrphanedScores struct {
+	BeatmapMd5 string
+	NumScores  int
+}
+
+// A collection entry which refers to a beatmap that is not in osu!.db
+type DanglingCollectionEntry struct {
+	Collection string
+	BeatmapMd5 string
+}
+
+// A beatmap MD5 which appears more than once in osu!.db
+type DuplicateBeatMap struct {
+	Md5 string
+	// The number of entries with this MD5
+	Count int
+}
+
+// A beatmap whose folder is missing from the Songs directory
+type MissingFolder struct {
+	Md5    string
+	Folder string
+}
+
+type ConsistencyReport struct {
+	OrphanedScores     []OrphanedScores
+	DanglingCollection []DanglingCollectionEntry
+	DuplicateBeatmaps  []DuplicateBeatMap
+	MissingFolders     []MissingFolder
+}
+
+// Returns true if no inconsistencies were found
+func (this *ConsistencyReport) Empty() bool {
+	return len(this.OrphanedScores) == 0 &&
+		len(this.DanglingCollection) == 0 &&
+		len(this.DuplicateBeatmaps) == 0 &&
+		len(this.MissingFolders) == 0
+}
+
+// Cross reference the DBs and report every inconsistency found. The scores,
+// collections and songsDir arguments are optional; pass nil (or "" for the
+// songsDir) to skip the checks which need them.
+// Args:
+//   osu: The osu!.db which holds the set of known beatmaps
+//   scores: The scores.db to check for orphaned scores
+//   collections: The collection.db to check for dangling entries
+//   songsDir: The osu! Songs directory to check for missing folders
+func CheckConsistency(osu *OsuDb, scores *ScoresDb, collections *CollectionDb,
+	songsDir string) (*ConsistencyReport, error) {
+	report := &ConsistencyReport{}
+
+	known := make(map[string]int, len(osu.Beatmaps))
+	var order []string
+	for i := range osu.Beatmaps {
+		md5 := osu.Beatmaps[i].Md5.Text
+		if _, ok := known[md5]; !ok {
+			order = append(order, md5)
+		}
+		known[md5]++
+	}
+	for _, md5 := range order {
+		if count := known[md5]; count > 1 {
+			report.DuplicateBeatmaps = append(report.DuplicateBeatmaps,
+				DuplicateBeatMap{md5, count})
+		}
+	}
+
+	if scores != nil {
+		for i := range scores.Beatmaps {
+			md5 := scores.Beatmaps[i].Md5Hash.Text
+			if _, ok := known[md5]; !ok {
+				report.OrphanedScores = append(report.OrphanedScores,
+					OrphanedScores{md5, len(scores.Beatmaps[i].Scores)})
+			}
+		}
+	}
+
+	if collections != nil {
+		for i := range collections.Collections {
+			collection := &collections.Collections[i]
+			for _, hash := range collection.BeatmapMd5Hashes {
+				if _, ok := known[hash.Text]; !ok {
+					report.DanglingCollection = append(report.DanglingCollection,
+						DanglingCollectionEntry{collection.Name.Text, hash.Text})
+				}
+			}
+		}
+	}
+
+	if songsDir != "" {
+		checked := make(map[string]bool)
+		for i := range osu.Beatmaps {
+			folder := osu.Beatmaps[i].RelativeFolderName.Text
+			exists, ok := checked[folder]
+			if !ok {
+				_, err := os.Stat(filepath.Join(songsDir, fromOsuPath(folder)))
+				if err != nil && !os.IsNotExist(err) {
+					return nil, err
+				}
+				exists = err == nil
+				checked[folder] = exists
+			}
+			if !exists {
+				report.MissingFolders = append(report.MissingFolders,
+					MissingFolder{osu.Beatmaps[i].Md5.Text, folder})
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// Selects which of the reported inconsistencies Prune removes
+type PruneOptions struct {
+	OrphanedScores     bool
+	DanglingCollection bool
+	DuplicateBeatmaps  bool
+	MissingFolders     bool
+}
+
+// Remove the reported inconsistencies from the DBs in place, updating the
+// count fields to match. Duplicate beatmaps keep their first entry. Beatmaps
+// removed for their missing folder also have their scores and collection
+// entries removed, so that none are left dangling. The report refers to
+// beatmaps by MD5, so the DBs may have changed since it was made. Any of the
+// DBs may be nil, in which case it is left alone.
+func (this *ConsistencyReport) Prune(osu *OsuDb, scores *ScoresDb,
+	collections *CollectionDb, options PruneOptions) {
+	// The MD5s of the beatmaps which are no longer in osu!.db
+	removed := make(map[string]bool)
+	if osu != nil && (options.DuplicateBeatmaps || options.MissingFolders) {
+		duplicate := make(map[string]bool)
+		if options.DuplicateBeatmaps {
+			for _, d := range this.DuplicateBeatmaps {
+				duplicate[d.Md5] = true
+			}
+		}
+		missing := make(map[MissingFolder]bool)
+		if options.MissingFolders {
+			for _, folder := range this.MissingFolders {
+				missing[folder] = true
+			}
+		}
+
+		kept := make(map[string]bool)
+		beatmaps := osu.Beatmaps[:0]
+		for i := range osu.Beatmaps {
+			beatmap := &osu.Beatmaps[i]
+			md5 := beatmap.Md5.Text
+			if missing[MissingFolder{md5, beatmap.RelativeFolderName.Text}] ||
+				(duplicate[md5] && kept[md5]) {
+				removed[md5] = true
+				continue
+			}
+			kept[md5] = true
+			beatmaps = append(beatmaps, *beatmap)
+		}
+		for md5 := range kept {
+			delete(removed, md5)
+		}
+		osu.Beatmaps = beatmaps
+		osu.NumBeatmaps = Int(len(beatmaps))
+		osu.FolderCount = Int(countFolders(beatmaps))
+	}
+
+	if scores != nil && (options.OrphanedScores || len(removed) > 0) {
+		orphaned := make(map[string]bool, len(this.OrphanedScores))
+		if options.OrphanedScores {
+			for _, orphan := range this.OrphanedScores {
+				orphaned[orphan.BeatmapMd5] = true
+			}
+		}
+		beatmaps := scores.Beatmaps[:0]
+		for i := range scores.Beatmaps {
+			md5 := scores.Beatmaps[i].Md5Hash.Text
+			if !orphaned[md5] && !removed[md5] {
+				beatmaps = append(beatmaps, scores.Beatmaps[i])
+			}
+		}
+		scores.Beatmaps = beatmaps
+		scores.NumBeatmaps = Int(len(beatmaps))
+	}
+
+	if collections != nil && (options.DanglingCollection || len(removed) > 0) {
+		dangling := make(map[DanglingCollectionEntry]bool, len(this.DanglingCollection))
+		if options.DanglingCollection {
+			for _, entry := range this.DanglingCollection {
+				dangling[entry] = true
+			}
+		}
+		for i := range collections.Collections {
+			collection := &collections.Collections[i]
+			hashes := collection.BeatmapMd5Hashes[:0]
+			for _, hash := range collection.BeatmapMd5Hashes {
+				if !dangling[DanglingCollectionEntry{collection.Name.Text, hash.Text}] &&
+					!removed[hash.Text] {
+					hashes = append(hashes, hash)
+				}
+			}
+			collection.BeatmapMd5Hashes = hashes
+			collection.NumBeatmapMd5Hashes = Int(len(hashes))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package gosu
+
+import (
+	"testing"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	beatmap := func(md5, folder string) BeatMap {
+		return BeatMap{Md5: NewString(md5), RelativeFolderName: NewString(folder)}
+	}
+	osu := NewOsuDb(Int(20171227), []BeatMap{
+		beatmap("aaaa", "1 gosu - Test Song"),
+		beatmap("bbbb", "2 missing"),
+		beatmap("aaaa", "1 gosu - Test Song"),
+	})
+	scores := &ScoresDb{
+		NumBeatmaps: 3,
+		Beatmaps: []ScoresDbBeatMap{
+			{Md5Hash: NewString("aaaa"), NumScores: 1, Scores: make([]ScoresDbBeatMapScore, 1)},
+			{Md5Hash: NewString("bbbb"), NumScores: 1, Scores: make([]ScoresDbBeatMapScore, 1)},
+			{Md5Hash: NewString("cccc"), NumScores: 2, Scores: make([]ScoresDbBeatMapScore, 2)},
+		},
+	}
+	collections := &CollectionDb{
+		NumCollections: 1,
+		Collections: []CollectionDbElement{{
+			Name:                NewString("favourites"),
+			NumBeatmapMd5Hashes: 2,
+			BeatmapMd5Hashes:    []String{NewString("bbbb"), NewString("dddd")},
+		}},
+	}
+
+	report, err := CheckConsistency(osu, scores, collections, "data/Songs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.DuplicateBeatmaps) != 1 || report.DuplicateBeatmaps[0].Count != 2 {
+		t.Errorf("Expected one duplicate beatmap, got %v", report.DuplicateBeatmaps)
+	}
+	if len(report.OrphanedScores) != 1 || report.OrphanedScores[0].NumScores != 2 {
+		t.Errorf("Expected the cccc scores to be orphaned, got %v", report.OrphanedScores)
+	}
+	if len(report.DanglingCollection) != 1 || report.DanglingCollection[0].BeatmapMd5 != "dddd" {
+		t.Errorf("Expected dddd to be dangling, got %v", report.DanglingCollection)
+	}
+	if len(report.MissingFolders) != 1 || report.MissingFolders[0].Md5 != "bbbb" {
+		t.Errorf("Expected the bbbb folder to be missing, got %v", report.MissingFolders)
+	}
+
+	// The report still applies after the beatmaps are reordered. Removing
+	// bbbb for its missing folder also removes its score and collection
+	// entry.
+	osu.Beatmaps[0], osu.Beatmaps[1] = osu.Beatmaps[1], osu.Beatmaps[0]
+	report.Prune(osu, scores, collections, PruneOptions{true, true, true, true})
+	if osu.NumBeatmaps != 1 || osu.Beatmaps[0].Md5.Text != "aaaa" || scores.NumBeatmaps != 1 ||
+		collections.Collections[0].NumBeatmapMd5Hashes != 0 {
+		t.Errorf("Prune left %d beatmaps, %d scored beatmaps and %d collection entries",
+			osu.NumBeatmaps, scores.NumBeatmaps, collections.Collections[0].NumBeatmapMd5Hashes)
+	}
+}
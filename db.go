@@ -4,6 +4,7 @@ package gosu
 // See https://github.com/ppy/osu-wiki/blob/master/wiki/osu!_File_Formats/Db_(file_format)/en.md
 // for the spec of these fields.
 
+//gosu:codec
 type OsuDb struct {
 	Version         Int
 	FolderCount     Int
@@ -15,6 +16,7 @@ type OsuDb struct {
 	Extra           Int
 }
 
+//gosu:codec
 type IntDoublePair struct {
 	ExtraBeforeInt    Byte
 	IntValue          Int
@@ -22,12 +24,14 @@ type IntDoublePair struct {
 	DoubleValue       Double
 }
 
+//gosu:codec
 type TimingPoint struct {
 	BPM         Double
 	OffsetMsec  Double
 	IsInherited Boolean
 }
 
+//gosu:codec
 type BeatMap struct {
 	SizeOfBeatmapBytes       Int
 	ArtistName               String
@@ -37,9 +41,9 @@ type BeatMap struct {
 	CreatorName              String
 	Difficulty               String
 	AudioFileName            String
-	Md5                      String
+	Md5                      String `osu-check:"md5"`
 	OsuFileName              String
-	RankedStatus             Byte
+	RankedStatus             Byte `osu-check:"ranked-status"`
 	NumHitCircles            Short
 	NumOfSliders             Short
 	NumOfSpinners            Short
@@ -69,13 +73,13 @@ type BeatMap struct {
 	BeatmapID                Int
 	BeatmapSetID             Int
 	ThreadID                 Int
-	GradeOsuStandard         Byte
-	GradeTaiko               Byte
-	GradeCTB                 Byte
-	GradeMania               Byte
+	GradeOsuStandard         Grade `osu-check:"grade"`
+	GradeTaiko               Grade `osu-check:"grade"`
+	GradeCTB                 Grade `osu-check:"grade"`
+	GradeMania               Grade `osu-check:"grade"`
 	LocalBeatmapOffset       Short
 	StackLeniency            Single
-	OsuGameplayMode          Byte
+	OsuGameplayMode          Byte `osu-check:"mode"`
 	SongSource               String
 	SongTags                 String
 	OnlineOffset             Short
@@ -95,36 +99,41 @@ type BeatMap struct {
 	ManiaScrollSpeed         Byte
 }
 
+//gosu:codec
 type CollectionDb struct {
 	Version        Int
 	NumCollections Int
 	Collections    []CollectionDbElement
 }
 
+//gosu:codec
 type CollectionDbElement struct {
 	Name                String
 	NumBeatmapMd5Hashes Int
-	BeatmapMd5Hashes    []String
+	BeatmapMd5Hashes    []String `osu-check:"md5"`
 }
 
+//gosu:codec
 type ScoresDb struct {
 	Version     Int
 	NumBeatmaps Int
 	Beatmaps    []ScoresDbBeatMap
 }
 
+//gosu:codec
 type ScoresDbBeatMap struct {
-	Md5Hash   String
+	Md5Hash   String `osu-check:"md5"`
 	NumScores Int
 	Scores    []ScoresDbBeatMapScore
 }
 
+//gosu:codec
 type ScoresDbBeatMapScore struct {
-	GameplayMode                 Byte
+	GameplayMode                 Byte `osu-check:"mode"`
 	Version                      Int
-	Md5Hash                      String
+	Md5Hash                      String `osu-check:"md5"`
 	PlayerName                   String
-	ReplayMd5Hash                String
+	ReplayMd5Hash                String `osu-check:"md5"`
 	Num300                       Short
 	Num200                       Short
 	Num50                        Short
@@ -141,12 +150,14 @@ type ScoresDbBeatMapScore struct {
 	OnlineScoreId                Long
 }
 
+//gosu:codec
 type PresenceDb struct {
 	Version    Int
 	NumPlayers Int
 	Players    []PlayerPresence
 }
 
+//gosu:codec
 type PlayerPresence struct {
 	PlayerId         Int
 	PlayerName       String
@@ -0,0 +1,325 @@
+package gosu
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A semantic diff between two versions of a DB. Entries are matched by their
+// identity rather than their position, so that the diff shows what the client
+// changed instead of how every following entry shifted:
+//   osu!.db: beatmaps by MD5
+//   scores.db: scores by beatmap MD5 and online score id or replay hash
+//   collection.db: collections by name
+//   presence.db: players by id
+
+// A change of a single field
+type FieldChange struct {
+	// The path of the field within its entry, e.g. "TimingPoints[3].BPM"
+	Field string
+	// The formatted values, empty when the field was added or removed
+	Old string
+	New string
+}
+
+// An entry which was added, removed or modified
+type EntryChange struct {
+	// "beatmap", "score", "collection" or "player"
+	Kind string
+	// The identity of the entry, e.g. the beatmap's MD5
+	Key string
+	// A readable name of the entry, e.g. "Artist - Title [Difficulty]"
+	Name string
+	// The fields which changed, only set for modified entries
+	Fields []FieldChange
+}
+
+// The changes between two DBs, see DiffDb
+type DbDiff struct {
+	// The changes to the fields outside of the entries, e.g. Version
+	Fields   []FieldChange
+	Added    []EntryChange
+	Removed  []EntryChange
+	Modified []EntryChange
+}
+
+// Returns true if the DBs are the same
+func (this *DbDiff) Empty() bool {
+	return len(this.Fields) == 0 && len(this.Added) == 0 &&
+		len(this.Removed) == 0 && len(this.Modified) == 0
+}
+
+// Diff two decoded DBs of the same kind
+// Args:
+//   older: Pointer to the older DB, e.g. a *OsuDb
+//   newer: Pointer to the newer DB, of the same type as older
+func DiffDb(older, newer interface{}) (*DbDiff, error) {
+	if reflect.TypeOf(older) != reflect.TypeOf(newer) {
+		return nil, fmt.Errorf("Can not diff a %T against a %T", older, newer)
+	}
+	diff := &DbDiff{}
+	switch a := older.(type) {
+	case *OsuDb:
+		b := newer.(*OsuDb)
+		diff.Fields = headerChanges(a, b, "Beatmaps")
+		diff.diffEntries(osuDbEntries(a), osuDbEntries(b))
+	case *ScoresDb:
+		b := newer.(*ScoresDb)
+		diff.Fields = headerChanges(a, b, "Beatmaps")
+		diff.diffEntries(scoresDbEntries(a), scoresDbEntries(b))
+	case *CollectionDb:
+		b := newer.(*CollectionDb)
+		diff.Fields = headerChanges(a, b, "Collections")
+		diff.diffEntries(collectionDbEntries(a), collectionDbEntries(b))
+	case *PresenceDb:
+		b := newer.(*PresenceDb)
+		diff.Fields = headerChanges(a, b, "Players")
+		diff.diffEntries(presenceDbEntries(a), presenceDbEntries(b))
+	case *Replay:
+		diff.Fields = headerChanges(a, newer, "")
+	default:
+		return nil, fmt.Errorf("Can not diff a %T", older)
+	}
+	return diff, nil
+}
+
+// An entry of a DB with its identity
+type diffEntry struct {
+	Kind  string
+	Key   string
+	Name  string
+	Value reflect.Value
+}
+
+func (this *DbDiff) diffEntries(older, newer []diffEntry) {
+	oldByKey := make(map[string]*diffEntry, len(older))
+	for i := range older {
+		oldByKey[older[i].Key] = &older[i]
+	}
+	newKeys := make(map[string]bool, len(newer))
+	for _, b := range newer {
+		newKeys[b.Key] = true
+		a, ok := oldByKey[b.Key]
+		if !ok {
+			this.Added = append(this.Added, EntryChange{Kind: b.Kind, Key: b.Key, Name: b.Name})
+			continue
+		}
+		var fields []FieldChange
+		if b.Kind == "collection" {
+			fields = collectionChanges(a.Value.Interface().(CollectionDbElement),
+				b.Value.Interface().(CollectionDbElement))
+		} else {
+			compareValues("", a.Value, b.Value, &fields)
+		}
+		if len(fields) > 0 {
+			this.Modified = append(this.Modified,
+				EntryChange{Kind: b.Kind, Key: b.Key, Name: b.Name, Fields: fields})
+		}
+	}
+	for _, a := range older {
+		if !newKeys[a.Key] {
+			this.Removed = append(this.Removed, EntryChange{Kind: a.Kind, Key: a.Key, Name: a.Name})
+		}
+	}
+}
+
+// Returns a key which is unique within the DB. Duplicate entries get the
+// number of their occurrence appended, e.g. "<md5>#2".
+func uniqueKey(seen map[string]int, key string) string {
+	seen[key]++
+	if n := seen[key]; n > 1 {
+		return fmt.Sprintf("%s#%d", key, n)
+	}
+	return key
+}
+
+func osuDbEntries(db *OsuDb) []diffEntry {
+	seen := make(map[string]int)
+	entries := make([]diffEntry, len(db.Beatmaps))
+	for i := range db.Beatmaps {
+		b := &db.Beatmaps[i]
+		entries[i] = diffEntry{"beatmap", uniqueKey(seen, b.Md5.Text),
+			fmt.Sprintf("%s - %s [%s]", b.ArtistName.Text, b.SongTitle.Text, b.Difficulty.Text),
+			reflect.ValueOf(b).Elem()}
+	}
+	return entries
+}
+
+func scoresDbEntries(db *ScoresDb) []diffEntry {
+	seen := make(map[string]int)
+	var entries []diffEntry
+	for i := range db.Beatmaps {
+		for j := range db.Beatmaps[i].Scores {
+			s := &db.Beatmaps[i].Scores[j]
+			id := s.ReplayMd5Hash.Text
+			if s.OnlineScoreId != 0 {
+				id = strconv.FormatUint(uint64(s.OnlineScoreId), 10)
+			}
+			entries = append(entries, diffEntry{"score",
+				uniqueKey(seen, s.Md5Hash.Text+"/"+id),
+				fmt.Sprintf("%s %d %s", s.PlayerName.Text, s.ReplayScore, Mods(s.Mods)),
+				reflect.ValueOf(s).Elem()})
+		}
+	}
+	return entries
+}
+
+func collectionDbEntries(db *CollectionDb) []diffEntry {
+	seen := make(map[string]int)
+	entries := make([]diffEntry, len(db.Collections))
+	for i := range db.Collections {
+		c := &db.Collections[i]
+		entries[i] = diffEntry{"collection", uniqueKey(seen, c.Name.Text), c.Name.Text,
+			reflect.ValueOf(c).Elem()}
+	}
+	return entries
+}
+
+func presenceDbEntries(db *PresenceDb) []diffEntry {
+	seen := make(map[string]int)
+	entries := make([]diffEntry, len(db.Players))
+	for i := range db.Players {
+		p := &db.Players[i]
+		entries[i] = diffEntry{"player",
+			uniqueKey(seen, strconv.FormatUint(uint64(p.PlayerId), 10)), p.PlayerName.Text,
+			reflect.ValueOf(p).Elem()}
+	}
+	return entries
+}
+
+// Compare the fields of the DBs which are not part of the entries
+func headerChanges(older, newer interface{}, entriesField string) []FieldChange {
+	a := reflect.ValueOf(older).Elem()
+	b := reflect.ValueOf(newer).Elem()
+	var changes []FieldChange
+	for i := 0; i < a.NumField(); i++ {
+		name := a.Type().Field(i).Name
+		if entriesField != "" && (name == entriesField || name == "Num"+entriesField) {
+			continue
+		}
+		compareValues(name, a.Field(i), b.Field(i), &changes)
+	}
+	return changes
+}
+
+// The beatmaps of a collection are a set, so report the added and removed
+// hashes instead of comparing them by position.
+func collectionChanges(older, newer CollectionDbElement) []FieldChange {
+	var changes []FieldChange
+	had := make(map[string]bool, len(older.BeatmapMd5Hashes))
+	for _, hash := range older.BeatmapMd5Hashes {
+		had[hash.Text] = true
+	}
+	has := make(map[string]bool, len(newer.BeatmapMd5Hashes))
+	for _, hash := range newer.BeatmapMd5Hashes {
+		has[hash.Text] = true
+		if !had[hash.Text] {
+			changes = append(changes, FieldChange{Field: "BeatmapMd5Hashes", New: hash.Text})
+		}
+	}
+	for _, hash := range older.BeatmapMd5Hashes {
+		if !has[hash.Text] {
+			changes = append(changes, FieldChange{Field: "BeatmapMd5Hashes", Old: hash.Text})
+		}
+	}
+	return changes
+}
+
+var byteSliceType = reflect.TypeOf([]Byte(nil))
+
+// Append the changes between the two values to changes, recursing into
+// structs and slices.
+func compareValues(path string, a, b reflect.Value, changes *[]FieldChange) {
+	join := func(name string) string {
+		if path == "" {
+			return name
+		}
+		return path + "." + name
+	}
+
+	switch {
+	case a.Type() == stringType || a.Type() == dateTimeType:
+		if a.Interface() != b.Interface() {
+			*changes = append(*changes, FieldChange{path, formatValue(a), formatValue(b)})
+		}
+	case a.Type() == byteSliceType:
+		if !bytes.Equal(a.Bytes(), b.Bytes()) {
+			*changes = append(*changes, FieldChange{path, formatValue(a), formatValue(b)})
+		}
+	case a.Kind() == reflect.Struct:
+		for i := 0; i < a.NumField(); i++ {
+			compareValues(join(a.Type().Field(i).Name), a.Field(i), b.Field(i), changes)
+		}
+	case a.Kind() == reflect.Slice:
+		for i := 0; i < a.Len() || i < b.Len(); i++ {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= b.Len():
+				*changes = append(*changes, FieldChange{Field: elemPath, Old: formatValue(a.Index(i))})
+			case i >= a.Len():
+				*changes = append(*changes, FieldChange{Field: elemPath, New: formatValue(b.Index(i))})
+			default:
+				compareValues(elemPath, a.Index(i), b.Index(i), changes)
+			}
+		}
+	default:
+		if a.Interface() != b.Interface() {
+			*changes = append(*changes, FieldChange{path, formatValue(a), formatValue(b)})
+		}
+	}
+}
+
+// Format a value of the DB structs for display
+func formatValue(val reflect.Value) string {
+	switch val.Type() {
+	case stringType:
+		return strconv.Quote(val.Interface().(String).Text)
+	case dateTimeType:
+		return val.Interface().(DateTime).Time().Format(time.RFC3339)
+	case byteSliceType:
+		return fmt.Sprintf("<%d bytes>", val.Len())
+	}
+	return fmt.Sprintf("%+v", val.Interface())
+}
+
+// Write the diff as a readable changelog. Added entries start with "+",
+// removed entries with "-" and modified entries with "~", followed by one
+// indented line per changed field.
+func (this *DbDiff) WriteChangelog(w io.Writer) error {
+	var b strings.Builder
+	writeField := func(indent string, change FieldChange) {
+		switch {
+		case change.Old == "":
+			fmt.Fprintf(&b, "%s+ %s: %s\n", indent, change.Field, change.New)
+		case change.New == "":
+			fmt.Fprintf(&b, "%s- %s: %s\n", indent, change.Field, change.Old)
+		default:
+			fmt.Fprintf(&b, "%s~ %s: %s -> %s\n", indent, change.Field, change.Old, change.New)
+		}
+	}
+	for _, change := range this.Fields {
+		writeField("", change)
+	}
+	for _, entry := range this.Added {
+		fmt.Fprintf(&b, "+ %s %s %s\n", entry.Kind, entry.Key, entry.Name)
+	}
+	for _, entry := range this.Removed {
+		fmt.Fprintf(&b, "- %s %s %s\n", entry.Kind, entry.Key, entry.Name)
+	}
+	for _, entry := range this.Modified {
+		fmt.Fprintf(&b, "~ %s %s %s\n", entry.Kind, entry.Key, entry.Name)
+		for _, change := range entry.Fields {
+			writeField("    ", change)
+		}
+	}
+	if b.Len() == 0 {
+		b.WriteString("No changes\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,110 @@
+package gosu
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDiffDb(t *testing.T) {
+	beatmap := func(md5, title string) BeatMap {
+		return BeatMap{
+			Md5:             NewString(md5),
+			SongTitle:       NewString(title),
+			NumTimingPoints: 1,
+			TimingPoints:    []TimingPoint{{BPM: 500, OffsetMsec: 0, IsInherited: 1}},
+		}
+	}
+	old := NewOsuDb(Int(20171227), []BeatMap{
+		beatmap("aaaa", "kept"),
+		beatmap("bbbb", "removed"),
+		beatmap("cccc", "changed"),
+	})
+	changed := beatmap("cccc", "renamed")
+	changed.TimingPoints = append(changed.TimingPoints, TimingPoint{BPM: 250, OffsetMsec: 1000, IsInherited: 1})
+	changed.NumTimingPoints = 2
+	updated := NewOsuDb(Int(20180101), []BeatMap{
+		beatmap("aaaa", "kept"),
+		changed,
+		beatmap("dddd", "added"),
+	})
+
+	diff, err := DiffDb(old, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Fields) != 1 || diff.Fields[0] != (FieldChange{"Version", "20171227", "20180101"}) {
+		t.Errorf("Unexpected header changes %+v", diff.Fields)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].Key != "dddd" {
+		t.Errorf("Expected dddd to be added, got %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Key != "bbbb" {
+		t.Errorf("Expected bbbb to be removed, got %+v", diff.Removed)
+	}
+	if len(diff.Modified) != 1 || diff.Modified[0].Key != "cccc" {
+		t.Fatalf("Expected cccc to be modified, got %+v", diff.Modified)
+	}
+	fields := diff.Modified[0].Fields
+	expected := []string{"SongTitle", "NumTimingPoints", "TimingPoints[1]"}
+	if len(fields) != len(expected) {
+		t.Fatalf("Expected changes to %v, got %+v", expected, fields)
+	}
+	for i, field := range expected {
+		if fields[i].Field != field {
+			t.Errorf("Expected change %d to be %s, got %+v", i, field, fields[i])
+		}
+	}
+	if fields[0].Old != `"changed"` || fields[0].New != `"renamed"` || fields[2].Old != "" {
+		t.Errorf("Unexpected field changes %+v", fields)
+	}
+
+	var changelog bytes.Buffer
+	if err := diff.WriteChangelog(&changelog); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(changelog.String(), `    ~ SongTitle: "changed" -> "renamed"`) {
+		t.Errorf("Unexpected changelog:\n%s", changelog.String())
+	}
+
+	if _, err := DiffDb(old, &ScoresDb{}); err == nil {
+		t.Error("Expected diffing different kinds of DBs to fail")
+	}
+}
+
+func TestDiffCollectionDb(t *testing.T) {
+	file, err := os.Open("data/collection.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	old := new(CollectionDb)
+	if err := old.UnmarshalOsuBinary(file, Int(20171227)); err != nil {
+		t.Fatal(err)
+	}
+	diff, err := DiffDb(old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Errorf("Expected no changes, got %+v", diff)
+	}
+
+	changed := *old
+	changed.Collections = append([]CollectionDbElement(nil), old.Collections...)
+	first := &changed.Collections[0]
+	first.BeatmapMd5Hashes = append([]String{NewString("0123456789abcdef0123456789abcdef")},
+		first.BeatmapMd5Hashes[1:]...)
+	diff, err = DiffDb(old, &changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Modified) != 1 || len(diff.Modified[0].Fields) != 2 {
+		t.Fatalf("Expected one added and one removed hash, got %+v", diff.Modified)
+	}
+	if diff.Modified[0].Fields[0].New != "0123456789abcdef0123456789abcdef" ||
+		diff.Modified[0].Fields[1].Old != old.Collections[0].BeatmapMd5Hashes[0].Text {
+		t.Errorf("Unexpected changes %+v", diff.Modified[0].Fields)
+	}
+}
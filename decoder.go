@@ -0,0 +1,404 @@
+package gosu
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"sync"
+)
+
+// A faster alternative to UnmarshalOsuBinary for decoding whole files. The
+// Decoder reads straight from a byte slice with a cursor instead of calling
+// binary.Read for every field, and works out the field layout of each struct
+// once instead of on every value. It decodes exactly what UnmarshalAny does.
+
+// Decodes the DB structs from a byte slice
+type Decoder struct {
+	data    []byte
+	pos     int
+	version Int
+	// When true, equal strings share their memory. This saves a lot of
+	// memory for osu!.db, where artist and creator names repeat for every
+	// difficulty of a beatmap set.
+	InternStrings bool
+	interned      map[string]string
+}
+
+// Create a decoder which decodes the data as the given version
+func NewDecoder(data []byte, version Int) *Decoder {
+	return &Decoder{data: data, version: version}
+}
+
+// Returns the number of bytes decoded so far
+func (this *Decoder) Offset() int {
+	return this.pos
+}
+
+// Move the cursor to the given offset in the data
+func (this *Decoder) Seek(offset int) error {
+	if offset < 0 || offset > len(this.data) {
+		return fmt.Errorf("Offset %d is outside of the data [0, %d]", offset, len(this.data))
+	}
+	this.pos = offset
+	return nil
+}
+
+// Decode the next value into db, which must be a pointer to a DB struct
+func (this *Decoder) Decode(db interface{}) error {
+	val := reflect.ValueOf(db)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return errors.New("Decode needs a pointer to a struct")
+	}
+	plan, err := planOf(val.Elem().Type())
+	if err != nil {
+		return err
+	}
+	return this.decodeStruct(val.Elem(), plan)
+}
+
+// Decode only the fields [first, last) of the struct db points to, for
+// decoding part of a file.
+func (this *Decoder) decodeFields(db interface{}, first int, last int) error {
+	val := reflect.ValueOf(db).Elem()
+	plan, err := planOf(val.Type())
+	if err != nil {
+		return err
+	}
+	return this.decodeStruct(val, plan[first:last])
+}
+
+// How a value is decoded
+type decodeKind int
+
+const (
+	decodeUint8 decodeKind = iota
+	decodeUint16
+	decodeUint32
+	decodeUint64
+	decodeFloat32
+	decodeFloat64
+	decodeULEB128
+	decodeString
+	decodeStruct
+	decodeSlice
+)
+
+// The decoding plan of a struct field or slice element
+type fieldPlan struct {
+	Index int
+	Kind  decodeKind
+	// The version range of the field, 0 when unbounded
+	Start Int
+	End   Int
+	// The index of the Num<Field> count of a slice
+	CountIndex int
+	// The plan of the elements of a slice
+	Elem *fieldPlan
+	// The plan of the fields of a struct
+	Struct []fieldPlan
+}
+
+var decodePlans sync.Map
+
+// Returns the decoding plan of the struct's fields, building it on first use
+func planOf(structType reflect.Type) ([]fieldPlan, error) {
+	if plan, ok := decodePlans.Load(structType); ok {
+		return plan.([]fieldPlan), nil
+	}
+	var plan []fieldPlan
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		fieldPlan, err := planOfType(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %v", structType.Name(), field.Name, err)
+		}
+		fieldPlan.Index = i
+		for _, tag := range []struct {
+			Name    string
+			Version *Int
+		}{{"osu-start", &fieldPlan.Start}, {"osu-end", &fieldPlan.End}} {
+			if value, ok := field.Tag.Lookup(tag.Name); ok {
+				parsed, err := strconv.ParseUint(value, 10, 32)
+				if err != nil {
+					return nil, err
+				}
+				*tag.Version = Int(parsed)
+			}
+		}
+		if fieldPlan.Kind == decodeSlice {
+			count, ok := structType.FieldByName("Num" + field.Name)
+			if !ok || count.Type != reflect.TypeOf(Int(0)) {
+				return nil, fmt.Errorf("%s.%s has no Int count Num%s",
+					structType.Name(), field.Name, field.Name)
+			}
+			fieldPlan.CountIndex = count.Index[0]
+		}
+		plan = append(plan, fieldPlan)
+	}
+	decodePlans.Store(structType, plan)
+	return plan, nil
+}
+
+func planOfType(t reflect.Type) (fieldPlan, error) {
+	switch t {
+	case stringType:
+		return fieldPlan{Kind: decodeString}, nil
+	case ulebType:
+		return fieldPlan{Kind: decodeULEB128}, nil
+	}
+	switch t.Kind() {
+	case reflect.Uint8:
+		return fieldPlan{Kind: decodeUint8}, nil
+	case reflect.Uint16:
+		return fieldPlan{Kind: decodeUint16}, nil
+	case reflect.Uint32:
+		return fieldPlan{Kind: decodeUint32}, nil
+	case reflect.Uint64:
+		return fieldPlan{Kind: decodeUint64}, nil
+	case reflect.Float32:
+		return fieldPlan{Kind: decodeFloat32}, nil
+	case reflect.Float64:
+		return fieldPlan{Kind: decodeFloat64}, nil
+	case reflect.Struct:
+		plan, err := planOf(t)
+		return fieldPlan{Kind: decodeStruct, Struct: plan}, err
+	case reflect.Slice:
+		elem, err := planOfType(t.Elem())
+		return fieldPlan{Kind: decodeSlice, Elem: &elem}, err
+	}
+	return fieldPlan{}, fmt.Errorf("Can not decode a %s", t)
+}
+
+func (this *Decoder) decodeStruct(val reflect.Value, plan []fieldPlan) error {
+	for i := range plan {
+		field := &plan[i]
+		if (field.End != 0 && this.version > field.End) ||
+			(field.Start != 0 && this.version < field.Start) {
+			continue
+		}
+		if err := this.decodeValue(val.Field(field.Index), field, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode a single value. parent is the struct holding the value, which is
+// needed for the counts of slices.
+func (this *Decoder) decodeValue(val reflect.Value, plan *fieldPlan, parent reflect.Value) error {
+	switch plan.Kind {
+	case decodeUint8:
+		b, err := this.next(1)
+		if err != nil {
+			return err
+		}
+		val.SetUint(uint64(b[0]))
+	case decodeUint16:
+		b, err := this.next(2)
+		if err != nil {
+			return err
+		}
+		val.SetUint(uint64(binary.LittleEndian.Uint16(b)))
+	case decodeUint32:
+		b, err := this.next(4)
+		if err != nil {
+			return err
+		}
+		val.SetUint(uint64(binary.LittleEndian.Uint32(b)))
+	case decodeUint64:
+		b, err := this.next(8)
+		if err != nil {
+			return err
+		}
+		val.SetUint(binary.LittleEndian.Uint64(b))
+	case decodeFloat32:
+		b, err := this.next(4)
+		if err != nil {
+			return err
+		}
+		val.SetFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(b))))
+	case decodeFloat64:
+		b, err := this.next(8)
+		if err != nil {
+			return err
+		}
+		val.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(b)))
+	case decodeULEB128:
+		n, err := this.uleb128()
+		if err != nil {
+			return err
+		}
+		val.SetUint(n)
+	case decodeString:
+		str, err := this.string()
+		if err != nil {
+			return err
+		}
+		val.Set(reflect.ValueOf(str))
+	case decodeStruct:
+		return this.decodeStruct(val, plan.Struct)
+	case decodeSlice:
+		count := int(parent.Field(plan.CountIndex).Uint())
+		if plan.Elem.Kind == decodeUint8 {
+			// Raw byte arrays are copied in one go
+			b, err := this.next(count)
+			if err != nil {
+				return err
+			}
+			// Copied, since the data may be a memory mapped file
+			val.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+		// Every element takes at least a byte, so a larger count is corrupt
+		if count > len(this.data)-this.pos {
+			return fmt.Errorf("Count of %d elements at offset %d exceeds the data", count, this.pos)
+		}
+		slice := reflect.MakeSlice(val.Type(), count, count)
+		for i := 0; i < count; i++ {
+			if err := this.decodeValue(slice.Index(i), plan.Elem, val); err != nil {
+				return err
+			}
+		}
+		val.Set(slice)
+	}
+	return nil
+}
+
+// Returns the next n bytes and moves the cursor past them
+func (this *Decoder) next(n int) ([]byte, error) {
+	if n > len(this.data)-this.pos {
+		return nil, fmt.Errorf("Unexpected end of data at offset %d", this.pos)
+	}
+	b := this.data[this.pos : this.pos+n]
+	this.pos += n
+	return b, nil
+}
+
+func (this *Decoder) uleb128() (uint64, error) {
+	var value uint64
+	for shift := uint(0); ; shift += 7 {
+		b, err := this.next(1)
+		if err != nil {
+			return 0, err
+		}
+		if shift < 64 {
+			value |= uint64(b[0]&0x7f) << shift
+		}
+		if b[0]&0x80 == 0 {
+			return value, nil
+		}
+	}
+}
+
+func (this *Decoder) string() (String, error) {
+	var str String
+	cond, err := this.next(1)
+	if err != nil {
+		return str, err
+	}
+	str.Cond = Byte(cond[0])
+	if str.Cond != 0xb {
+		return str, nil
+	}
+	length, err := this.uleb128()
+	if err != nil {
+		return str, err
+	}
+	str.Len = ULEB128(length)
+	if length > uint64(len(this.data)-this.pos) {
+		return str, fmt.Errorf("String of %d bytes at offset %d exceeds the data", length, this.pos)
+	}
+	text, _ := this.next(int(length))
+
+	if !this.InternStrings {
+		str.Text = string(text)
+		return str, nil
+	}
+	if this.interned == nil {
+		this.interned = make(map[string]string)
+	}
+	if interned, ok := this.interned[string(text)]; ok {
+		str.Text = interned
+	} else {
+		str.Text = string(text)
+		this.interned[str.Text] = str.Text
+	}
+	return str, nil
+}
+
+// Decode the DB file at the path into db, memory mapping the file where the
+// platform supports it. The version is read from the file. Do not use it on
+// files another process may rewrite while they are decoded, such as the DBs
+// of a running client: a truncated file fails to decode, but a file changed
+// in place can decode to a mix of its old and new contents. Read such files
+// with ioutil.ReadFile and decode them with a Decoder instead.
+// Args:
+//   path: The file to decode
+//   db: Pointer to the DB struct to decode into, e.g. a *OsuDb
+func DecodeFile(path string, db interface{}) error {
+	mapped, err := MapFile(path)
+	if err != nil {
+		return err
+	}
+	defer mapped.Close()
+
+	version, err := versionOfData(db, mapped.Data)
+	if err != nil {
+		return err
+	}
+	decoder := NewDecoder(mapped.Data, version)
+	decoder.InternStrings = true
+	return decodeMapped(func() error { return decoder.Decode(db) })
+}
+
+// Run a decode of a memory mapped file. Reading a mapped file which another
+// process truncated raises a fault, which would crash the program. It is
+// returned as an error instead.
+func decodeMapped(decode func() error) (err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			fault, ok := r.(interface{ Addr() uintptr })
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("The mapped file was truncated while it was decoded (fault at %#x)", fault.Addr())
+		}
+	}()
+	return decode()
+}
+
+// Returns the version stored at the start of the data
+func versionOfData(db interface{}, data []byte) (Int, error) {
+	offset := 0
+	if _, ok := db.(*Replay); ok {
+		// Replays start with the game mode
+		offset = 1
+	}
+	if len(data) < offset+4 {
+		return 0, errors.New("The data is too short to hold a version")
+	}
+	return Int(binary.LittleEndian.Uint32(data[offset:])), nil
+}
+
+// A file mapped into memory, see MapFile
+type MappedFile struct {
+	// The contents of the file. Only valid until Close is called.
+	Data  []byte
+	unmap func() error
+}
+
+// Release the memory of the file
+func (this *MappedFile) Close() error {
+	if this.unmap == nil {
+		return nil
+	}
+	err := this.unmap()
+	this.unmap = nil
+	this.Data = nil
+	return err
+}
@@ -0,0 +1,81 @@
+package gosu
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/d4l3k/messagediff"
+)
+
+func TestDecoder(t *testing.T) {
+	testcases := []struct {
+		Path string
+		Db   interface{}
+		Want BinaryOsuCodec
+	}{
+		{"data/osu!.db", new(OsuDb), new(OsuDb)},
+		{"data/scores.db", new(ScoresDb), new(ScoresDb)},
+		{"data/collection.db", new(CollectionDb), new(CollectionDb)},
+		{"data/presence.db", new(PresenceDb), new(PresenceDb)},
+	}
+	for _, testcase := range testcases {
+		data, err := ioutil.ReadFile(testcase.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := testcase.Want.UnmarshalOsuBinary(bytes.NewReader(data), Int(20171227)); err != nil {
+			t.Fatal(err)
+		}
+		if err := DecodeFile(testcase.Path, testcase.Db); err != nil {
+			t.Fatalf("%s: %v", testcase.Path, err)
+		}
+		if diff, equal := messagediff.PrettyDiff(testcase.Want, testcase.Db); !equal {
+			t.Errorf("%s: Decoder decoded different values.\n%s", testcase.Path, diff)
+		}
+	}
+
+	// Truncated data fails instead of panicking
+	data, err := ioutil.ReadFile("data/presence.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewDecoder(data[:100], Int(20171227)).Decode(new(PresenceDb)); err == nil {
+		t.Error("Expected decoding truncated data to fail")
+	}
+}
+
+func benchmarkOsuDb(b *testing.B, decode func(data []byte) error) {
+	data, err := ioutil.ReadFile("data/osu!.db")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := decode(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalOsuDb(b *testing.B) {
+	benchmarkOsuDb(b, func(data []byte) error {
+		return new(OsuDb).UnmarshalOsuBinary(bytes.NewReader(data), Int(20171227))
+	})
+}
+
+func BenchmarkDecoderOsuDb(b *testing.B) {
+	benchmarkOsuDb(b, func(data []byte) error {
+		return NewDecoder(data, Int(20171227)).Decode(new(OsuDb))
+	})
+}
+
+func BenchmarkDecoderOsuDbInterned(b *testing.B) {
+	benchmarkOsuDb(b, func(data []byte) error {
+		decoder := NewDecoder(data, Int(20171227))
+		decoder.InternStrings = true
+		return decoder.Decode(new(OsuDb))
+	})
+}
@@ -0,0 +1,111 @@
+package gosu
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Access to the fields of the DB structs by path. Paths use the same format
+// as ValidationError.Field, e.g. "Beatmaps[3].Scores[0].PlayerName".
+
+var dateTimeType = reflect.TypeOf(DateTime{})
+
+// Returns the value of the field at the path within the struct db points to
+func FieldByPath(db interface{}, path string) (reflect.Value, error) {
+	val := reflect.ValueOf(db)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, errors.New("FieldByPath needs a pointer to a struct")
+	}
+	val = val.Elem()
+	if path == "" {
+		return val, nil
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		name := part
+		var indices []int
+		if open := strings.IndexByte(part, '['); open >= 0 {
+			name = part[:open]
+			for _, index := range strings.Split(strings.TrimSuffix(part[open+1:], "]"), "][") {
+				i, err := strconv.Atoi(index)
+				if err != nil {
+					return reflect.Value{}, fmt.Errorf("Invalid index in %q", part)
+				}
+				indices = append(indices, i)
+			}
+		}
+
+		if val.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("%s has no field %s", val.Type(), name)
+		}
+		field := val.FieldByName(name)
+		if !field.IsValid() {
+			return reflect.Value{}, fmt.Errorf("%s has no field %s", val.Type(), name)
+		}
+		val = field
+		for _, i := range indices {
+			if val.Kind() != reflect.Slice {
+				return reflect.Value{}, fmt.Errorf("%s is not a slice", name)
+			}
+			if i < 0 || i >= val.Len() {
+				return reflect.Value{}, fmt.Errorf("Index %d of %s is out of range [0, %d)",
+					i, name, val.Len())
+			}
+			val = val.Index(i)
+		}
+	}
+	return val, nil
+}
+
+// Set the field at the path within the struct db points to, parsing the
+// value from text. Numbers accept any base strconv understands, Strings are
+// set with NewString and DateTimes accept either RFC 3339 or .NET ticks.
+// Slices and other structs can not be set. The Num fields of slices are not
+// updated, so changing them makes the db invalid.
+// Args:
+//   db: Pointer to the struct to change
+//   path: The path of the field, e.g. "Beatmaps[3].SongTitle"
+//   value: The new value of the field as text
+func SetFieldByPath(db interface{}, path string, value string) error {
+	field, err := FieldByPath(db, path)
+	if err != nil {
+		return err
+	}
+
+	switch field.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 0, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("Invalid value for %s: %v", path, err)
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("Invalid value for %s: %v", path, err)
+		}
+		field.SetFloat(f)
+	default:
+		switch field.Type() {
+		case stringType:
+			field.Set(reflect.ValueOf(NewString(value)))
+		case dateTimeType:
+			ticks, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				t, terr := time.Parse(time.RFC3339, value)
+				if terr != nil {
+					return fmt.Errorf("Invalid value for %s: expected RFC 3339 or ticks", path)
+				}
+				ticks = TimeToTicks(t)
+			}
+			field.Set(reflect.ValueOf(DateTime{ticks}))
+		default:
+			return fmt.Errorf("Can not set %s of type %s", path, field.Type())
+		}
+	}
+	return nil
+}
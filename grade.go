@@ -0,0 +1,172 @@
+package gosu
+
+import (
+	"fmt"
+)
+
+// The grade (rank) of a play, as stored in the BeatMap grade fields. The
+// values match the client's internal ranking enum.
+//
+//gosu:codec binary
+type Grade uint8
+
+const (
+	GradeSilverSS Grade = 0
+	GradeSilverS  Grade = 1
+	GradeSS       Grade = 2
+	GradeS        Grade = 3
+	GradeA        Grade = 4
+	GradeB        Grade = 5
+	GradeC        Grade = 6
+	GradeD        Grade = 7
+	GradeF        Grade = 8
+	// The beatmap has not been played in this mode
+	GradeNone Grade = 9
+)
+
+var gradeNames = [...]struct {
+	Name        string
+	DisplayName string
+}{
+	{"XH", "Silver SS"},
+	{"SH", "Silver S"},
+	{"X", "SS"},
+	{"S", "S"},
+	{"A", "A"},
+	{"B", "B"},
+	{"C", "C"},
+	{"D", "D"},
+	{"F", "F"},
+	{"N", "None"},
+}
+
+// Returns the client's internal name for the grade, e.g. "XH" for a silver SS
+func (this Grade) String() string {
+	if int(this) >= len(gradeNames) {
+		return fmt.Sprintf("Grade(%d)", uint8(this))
+	}
+	return gradeNames[this].Name
+}
+
+// Returns the name of the grade as shown to players, e.g. "Silver SS"
+func (this Grade) DisplayName() string {
+	if int(this) >= len(gradeNames) {
+		return this.String()
+	}
+	return gradeNames[this].DisplayName
+}
+
+// Returns the accuracy (between 0 and 1) of the hit counts in the game mode
+func Accuracy(mode GameMode, counts HitCounts) float64 {
+	var hit, total float64
+	switch mode {
+	case ModeTaiko:
+		hit = float64(counts.Count300) + 0.5*float64(counts.Count100)
+		total = float64(counts.Count300 + counts.Count100 + counts.CountMiss)
+	case ModeCatch:
+		// Katus count the missed droplets
+		hit = float64(counts.Count300 + counts.Count100 + counts.Count50)
+		total = hit + float64(counts.CountKatu+counts.CountMiss)
+	case ModeMania:
+		// Gekis are MAX judgements and katus are 200s
+		hit = 300*float64(counts.CountGeki+counts.Count300) +
+			200*float64(counts.CountKatu) + 100*float64(counts.Count100) +
+			50*float64(counts.Count50)
+		total = 300 * float64(counts.CountGeki+counts.Count300+counts.CountKatu+
+			counts.Count100+counts.Count50+counts.CountMiss)
+	default:
+		hit = 300*float64(counts.Count300) + 100*float64(counts.Count100) +
+			50*float64(counts.Count50)
+		total = 300 * float64(counts.Count300+counts.Count100+counts.Count50+counts.CountMiss)
+	}
+	if total == 0 {
+		return 0
+	}
+	return hit / total
+}
+
+// Returns the grade of the hit counts in the game mode. Hidden and
+// Flashlight (and FadeIn in osu!mania) turn SS and S grades silver.
+func ComputeGrade(mode GameMode, counts HitCounts, mods Mods) Grade {
+	var grade Grade
+	switch mode {
+	case ModeCatch:
+		grade = gradeByAccuracy(Accuracy(mode, counts), 0.98, 0.94, 0.90, 0.85)
+	case ModeMania:
+		grade = gradeByAccuracy(Accuracy(mode, counts), 0.95, 0.90, 0.80, 0.70)
+	default:
+		grade = gradeByRatio300(counts, mode == ModeStandard)
+	}
+
+	silver := mods.Has(ModHidden) || mods.Has(ModFlashlight) ||
+		(mode == ModeMania && mods.Has(ModFadeIn))
+	if silver {
+		switch grade {
+		case GradeSS:
+			return GradeSilverSS
+		case GradeS:
+			return GradeSilverS
+		}
+	}
+	return grade
+}
+
+// The osu!standard and osu!taiko grades, which depend on the ratio of 300s
+func gradeByRatio300(counts HitCounts, limit50s bool) Grade {
+	total := counts.Count300 + counts.Count100 + counts.Count50 + counts.CountMiss
+	if total == 0 {
+		return GradeD
+	}
+	ratio300 := float64(counts.Count300) / float64(total)
+	ratio50 := float64(counts.Count50) / float64(total)
+	switch {
+	case ratio300 == 1:
+		return GradeSS
+	case ratio300 > 0.9 && counts.CountMiss == 0 && (!limit50s || ratio50 <= 0.01):
+		return GradeS
+	case (ratio300 > 0.8 && counts.CountMiss == 0) || ratio300 > 0.9:
+		return GradeA
+	case (ratio300 > 0.7 && counts.CountMiss == 0) || ratio300 > 0.8:
+		return GradeB
+	case ratio300 > 0.6:
+		return GradeC
+	}
+	return GradeD
+}
+
+// The osu!catch and osu!mania grades, which depend on the accuracy
+func gradeByAccuracy(accuracy, s, a, b, c float64) Grade {
+	switch {
+	case accuracy == 1:
+		return GradeSS
+	case accuracy > s:
+		return GradeS
+	case accuracy > a:
+		return GradeA
+	case accuracy > b:
+		return GradeB
+	case accuracy > c:
+		return GradeC
+	}
+	return GradeD
+}
+
+// Returns the accuracy (between 0 and 1) of the score
+func (this *ScoresDbBeatMapScore) Accuracy() float64 {
+	return Accuracy(GameMode(this.GameplayMode), this.HitCounts())
+}
+
+// Returns the grade of the score
+func (this *ScoresDbBeatMapScore) Grade() Grade {
+	return ComputeGrade(GameMode(this.GameplayMode), this.HitCounts(), Mods(this.Mods))
+}
+
+// Returns the accuracy (between 0 and 1) of the replay
+func (this *Replay) Accuracy() float64 {
+	return Accuracy(GameMode(this.GameplayMode), this.HitCounts())
+}
+
+// Returns the grade of the replay
+func (this *Replay) Grade() Grade {
+	return ComputeGrade(GameMode(this.GameplayMode), this.HitCounts(), Mods(this.Mods))
+}
@@ -0,0 +1,51 @@
+package gosu
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAccuracyAndGrade(t *testing.T) {
+	testcases := []struct {
+		Mode     GameMode
+		Counts   HitCounts
+		Mods     Mods
+		Accuracy float64
+		Grade    Grade
+	}{
+		{ModeStandard, HitCounts{Count300: 100}, 0, 1, GradeSS},
+		{ModeStandard, HitCounts{Count300: 100}, ModHidden, 1, GradeSilverSS},
+		{ModeStandard, HitCounts{Count300: 74, Count100: 12, Count50: 1}, 0, 23450.0 / 26100, GradeA},
+		{ModeStandard, HitCounts{Count300: 95, Count100: 3, Count50: 2}, 0, 28900.0 / 30000, GradeA},
+		{ModeStandard, HitCounts{Count300: 95, Count100: 4, Count50: 1}, ModFlashlight, 28950.0 / 30000, GradeSilverS},
+		{ModeStandard, HitCounts{Count300: 95, Count100: 4, CountMiss: 1}, 0, 28900.0 / 30000, GradeA},
+		{ModeStandard, HitCounts{Count300: 50, CountMiss: 50}, 0, 0.5, GradeD},
+		{ModeTaiko, HitCounts{Count300: 90, Count100: 10}, 0, 0.95, GradeA},
+		{ModeTaiko, HitCounts{Count300: 95, Count100: 5}, ModHidden, 0.975, GradeSilverS},
+		{ModeCatch, HitCounts{Count300: 90, Count100: 5, Count50: 4, CountKatu: 1}, 0, 0.99, GradeS},
+		{ModeCatch, HitCounts{Count300: 90, CountMiss: 10}, 0, 0.9, GradeC},
+		{ModeMania, HitCounts{CountGeki: 50, Count300: 50}, ModFadeIn, 1, GradeSilverSS},
+		{ModeMania, HitCounts{CountGeki: 90, CountKatu: 10}, 0, 29000.0 / 30000, GradeS},
+		{ModeMania, HitCounts{Count300: 80, Count100: 20}, 0, 26000.0 / 30000, GradeB},
+	}
+
+	for i, testcase := range testcases {
+		accuracy := Accuracy(testcase.Mode, testcase.Counts)
+		if math.Abs(accuracy-testcase.Accuracy) > 1e-9 {
+			t.Errorf("%d: got accuracy %v, want %v", i, accuracy, testcase.Accuracy)
+		}
+		grade := ComputeGrade(testcase.Mode, testcase.Counts, testcase.Mods)
+		if grade != testcase.Grade {
+			t.Errorf("%d: got grade %s, want %s", i, grade, testcase.Grade)
+		}
+	}
+}
+
+func TestGradeNames(t *testing.T) {
+	if GradeSilverSS.String() != "XH" || GradeSilverSS.DisplayName() != "Silver SS" {
+		t.Errorf("Unexpected names for GradeSilverSS")
+	}
+	if GradeNone.DisplayName() != "None" || Grade(42).String() != "Grade(42)" {
+		t.Errorf("Unexpected names for GradeNone/Grade(42)")
+	}
+}
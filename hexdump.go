@@ -0,0 +1,196 @@
+package gosu
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+)
+
+// A dump of a binary DB which shows the offset, raw bytes, type and decoded
+// value of every field, for working out the format of new client versions.
+// It walks the fields in the same order and with the same version tags as
+// UnmarshalAny, so a field which looks wrong in the dump is where the
+// decoding drifts.
+
+// The number of raw bytes shown per field, longer fields are cut short
+const hexDumpMaxBytes = 16
+
+// Counts above this are reported as drift instead of allocating the slice
+const hexDumpMaxElements = 1 << 24
+
+// A single decoded field of a HexDump
+type dumpField struct {
+	// The path of the field, e.g. "Beatmaps[0].ArtistName"
+	Path string
+	// The position of the first byte of the field in the binary
+	Offset int64
+	Bytes  []byte
+	// The name of the field's type, e.g. "Int" or "String"
+	Type  string
+	Value string
+	// False if the field is not stored in this version of the format
+	Present bool
+}
+
+// Decode the binary into db while writing an annotated line per field to w.
+// When decoding fails the dump stops at the failing field and the error is
+// returned. Bytes left over after the last field are reported at the end.
+// Args:
+//   db: Pointer to the struct to decode into, e.g. a *OsuDb
+//   buf: The binary to decode
+//   version: The version of the binary
+//   w: Where to write the dump
+func HexDump(db interface{}, buf io.Reader, version Int, w io.Writer) error {
+	reader := &recordingReader{r: buf}
+	err := walkDump(reflect.ValueOf(db).Elem(), "", reader, version,
+		func(field dumpField) error {
+			_, err := io.WriteString(w, formatDumpField(field))
+			return err
+		})
+	if err != nil {
+		fmt.Fprintf(w, "0x%08x  error: %v\n", reader.offset, err)
+		return err
+	}
+
+	trailing, err := io.Copy(ioutil.Discard, buf)
+	if err != nil {
+		return err
+	}
+	if trailing > 0 {
+		fmt.Fprintf(w, "0x%08x  %d trailing bytes\n", reader.offset, trailing)
+	}
+	return nil
+}
+
+// Counts the bytes read and keeps the ones read since the last reset
+type recordingReader struct {
+	r      io.Reader
+	offset int64
+	bytes  []byte
+}
+
+func (this *recordingReader) Read(p []byte) (int, error) {
+	n, err := this.r.Read(p)
+	this.offset += int64(n)
+	this.bytes = append(this.bytes, p[:n]...)
+	return n, err
+}
+
+// Returns the bytes read since the last call
+func (this *recordingReader) take() []byte {
+	taken := this.bytes
+	this.bytes = nil
+	return taken
+}
+
+// Decode every field of the struct, recursing into nested structs and
+// slices, and pass each decoded leaf field to visit.
+func walkDump(dbVal reflect.Value, path string, reader *recordingReader, version Int,
+	visit func(dumpField) error) error {
+	dbType := dbVal.Type()
+	for i := 0; i < dbVal.NumField(); i++ {
+		currentField := dbVal.Field(i)
+		currentFieldType := dbType.Field(i)
+		fieldPath := currentFieldType.Name
+		if path != "" {
+			fieldPath = path + "." + fieldPath
+		}
+
+		ok, err := fieldInVersion(currentFieldType, version)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			err := visit(dumpField{Path: fieldPath, Offset: reader.offset,
+				Type: currentField.Type().Name()})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if currentField.Kind() != reflect.Slice {
+			if err := dumpValue(currentField, fieldPath, reader, version, visit); err != nil {
+				return err
+			}
+			continue
+		}
+
+		numElements, ok := dbVal.FieldByName("Num" + currentFieldType.Name).Interface().(Int)
+		if !ok {
+			return fmt.Errorf("%s has no Num%s count", fieldPath, currentFieldType.Name)
+		}
+		if numElements > hexDumpMaxElements {
+			return fmt.Errorf("Num%s is %d, the decoding has likely drifted",
+				currentFieldType.Name, numElements)
+		}
+		currentField.Set(reflect.MakeSlice(currentField.Type(), int(numElements), int(numElements)))
+
+		// Raw byte arrays are dumped as a single field
+		if currentField.Type() == byteSliceType {
+			offset := reader.offset
+			reader.take()
+			for j := 0; j < int(numElements); j++ {
+				if err := currentField.Index(j).Addr().Interface().(BinaryOsuUnmarshaler).
+					UnmarshalOsuBinary(reader, version); err != nil {
+					return fmt.Errorf("%s[%d]: %v", fieldPath, j, err)
+				}
+			}
+			err := visit(dumpField{fieldPath, offset, reader.take(), "[]Byte",
+				formatValue(currentField), true})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		for j := 0; j < int(numElements); j++ {
+			err := dumpValue(currentField.Index(j), fmt.Sprintf("%s[%d]", fieldPath, j),
+				reader, version, visit)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func dumpValue(val reflect.Value, path string, reader *recordingReader, version Int,
+	visit func(dumpField) error) error {
+	if val.Kind() == reflect.Struct && val.Type() != stringType && val.Type() != dateTimeType {
+		return walkDump(val, path, reader, version, visit)
+	}
+
+	unmarshaler, ok := val.Addr().Interface().(BinaryOsuUnmarshaler)
+	if !ok {
+		return errors.New(path + " of type " + val.Type().Name() + " has no codec")
+	}
+	offset := reader.offset
+	reader.take()
+	if err := unmarshaler.UnmarshalOsuBinary(reader, version); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return visit(dumpField{path, offset, reader.take(), val.Type().Name(), formatValue(val), true})
+}
+
+func formatDumpField(field dumpField) string {
+	if !field.Present {
+		return fmt.Sprintf("0x%08x  %-*s  %s  %s  (not in this version)\n",
+			field.Offset, hexDumpMaxBytes*3+6, "", field.Path, field.Type)
+	}
+	shown := field.Bytes
+	more := ""
+	if len(shown) > hexDumpMaxBytes {
+		shown = shown[:hexDumpMaxBytes]
+		more = fmt.Sprintf("+%d", len(field.Bytes)-hexDumpMaxBytes)
+	}
+	hex := make([]string, len(shown))
+	for i, b := range shown {
+		hex[i] = fmt.Sprintf("%02x", b)
+	}
+	return fmt.Sprintf("0x%08x  %-*s %5s  %s  %s  %s\n", field.Offset,
+		hexDumpMaxBytes*3, strings.Join(hex, " "), more, field.Path, field.Type, field.Value)
+}
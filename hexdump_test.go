@@ -0,0 +1,54 @@
+package gosu
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/d4l3k/messagediff"
+)
+
+func TestHexDump(t *testing.T) {
+	data, err := ioutil.ReadFile("data/presence.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump bytes.Buffer
+	dumped := new(PresenceDb)
+	if err := HexDump(dumped, bytes.NewReader(data), Int(20171227), &dump); err != nil {
+		t.Fatal(err)
+	}
+
+	// The dump decodes the same values as the codec
+	decoded := new(PresenceDb)
+	if err := decoded.UnmarshalOsuBinary(bytes.NewReader(data), Int(20171227)); err != nil {
+		t.Fatal(err)
+	}
+	if diff, equal := messagediff.PrettyDiff(decoded, dumped); !equal {
+		t.Errorf("HexDump decoded different values.\n%s", diff)
+	}
+
+	lines := strings.Split(dump.String(), "\n")
+	if !strings.HasPrefix(lines[0], "0x00000000  db c9 33 01") ||
+		!strings.HasSuffix(lines[0], "Version  Int  20171227") {
+		t.Errorf("Unexpected first line %q", lines[0])
+	}
+	if !strings.Contains(dump.String(), `Players[0].PlayerName  String  "stymphalian"`) {
+		t.Errorf("Expected the first player's name in the dump:\n%s", lines[:10])
+	}
+
+	// Decoding past the end of the data reports where it stopped
+	dump.Reset()
+	err = HexDump(new(PresenceDb), bytes.NewReader(data[:20]), Int(20171227), &dump)
+	if err == nil || !strings.Contains(dump.String(), "error: Players[0]") {
+		t.Errorf("Expected a decoding error, got %v:\n%s", err, dump.String())
+	}
+
+	// Trailing bytes are reported
+	dump.Reset()
+	err = HexDump(new(PresenceDb), bytes.NewReader(append(data, 1, 2, 3)), Int(20171227), &dump)
+	if err != nil || !strings.Contains(dump.String(), "3 trailing bytes") {
+		t.Errorf("Expected 3 trailing bytes, got %v:\n%s", err, dump.String())
+	}
+}
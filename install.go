@@ -0,0 +1,352 @@
+package gosu
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// An osu! install directory and the files in it. The DBs are decoded the
+// first time they are asked for. Installs inside a Wine prefix are found
+// from the prefix, and windows paths in their cfg are mapped to the prefix.
+
+// The paths of the files of an install, relative to its directory
+const (
+	osuDbFile        = "osu!.db"
+	collectionDbFile = "collection.db"
+	scoresDbFile     = "scores.db"
+	presenceDbFile   = "presence.db"
+	cfgFile          = "osu!.cfg"
+	songsDir         = "Songs"
+)
+
+var replayDir = filepath.Join("Data", "r")
+
+// Where osu! is installed inside a Wine prefix, or on Windows inside the
+// system drive. "*" matches any user.
+var installCandidates = []string{
+	filepath.Join("drive_c", "users", "*", "AppData", "Local", "osu!"),
+	filepath.Join("drive_c", "users", "*", "Local Settings", "Application Data", "osu!"),
+	filepath.Join("drive_c", "Program Files", "osu!"),
+	filepath.Join("drive_c", "Program Files (x86)", "osu!"),
+	filepath.Join("drive_c", "osu!"),
+}
+
+// An osu! install, see OpenInstall
+type Install struct {
+	// The install directory
+	Dir string
+	// The paths of the files of the install, empty for the ones it lacks
+	OsuDbPath        string
+	CollectionDbPath string
+	ScoresDbPath     string
+	PresenceDbPath   string
+	CfgPath          string
+	// The osu!.<user>.cfg of the player
+	UserCfgPath string
+	// The beatmap folder, as set by BeatmapDirectory in the user cfg
+	SongsDir string
+	// The folder of the replays of the scores in scores.db
+	ReplayDir string
+	// The Wine prefix the install is in, empty if it is not in one
+	WinePrefix string
+
+	mutex        sync.Mutex
+	osuDb        *OsuDb
+	collectionDb *CollectionDb
+	scoresDb     *ScoresDb
+	presenceDb   *PresenceDb
+}
+
+// Open the osu! install in the directory. The directory can also be a Wine
+// prefix with osu! installed in one of the usual places.
+// Args:
+//   dir: The install directory or Wine prefix
+func OpenInstall(dir string) (*Install, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !isInstallDir(dir) {
+		found, err := findInstallInPrefix(dir)
+		if err != nil {
+			return nil, err
+		}
+		if found == "" {
+			return nil, fmt.Errorf("%s is not an osu! install or a Wine prefix with one", dir)
+		}
+		dir = found
+	}
+
+	install := &Install{Dir: dir, WinePrefix: winePrefixOf(dir)}
+	for _, file := range []struct {
+		Name string
+		Path *string
+	}{
+		{osuDbFile, &install.OsuDbPath},
+		{collectionDbFile, &install.CollectionDbPath},
+		{scoresDbFile, &install.ScoresDbPath},
+		{presenceDbFile, &install.PresenceDbPath},
+		{cfgFile, &install.CfgPath},
+		{replayDir, &install.ReplayDir},
+	} {
+		if path := filepath.Join(dir, file.Name); exists(path) {
+			*file.Path = path
+		}
+	}
+	install.UserCfgPath, err = findUserCfg(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	install.SongsDir = filepath.Join(dir, songsDir)
+	if install.UserCfgPath != "" {
+		cfg, err := ReadCfg(install.UserCfgPath)
+		if err != nil {
+			return nil, err
+		}
+		if beatmapDir, ok := cfg.Get("BeatmapDirectory"); ok && beatmapDir != "" {
+			install.SongsDir = install.localPath(beatmapDir)
+		}
+	}
+	return install, nil
+}
+
+// Returns the osu! installs found in the usual places: the local app data
+// on Windows, and the Wine prefix in WINEPREFIX or ~/.wine elsewhere.
+func FindInstalls() []*Install {
+	var dirs []string
+	if appData := os.Getenv("LOCALAPPDATA"); appData != "" {
+		dirs = append(dirs, filepath.Join(appData, "osu!"))
+	}
+	if prefix := os.Getenv("WINEPREFIX"); prefix != "" {
+		dirs = append(dirs, prefix)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".wine"))
+	}
+
+	var installs []*Install
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		install, err := OpenInstall(dir)
+		if err != nil || seen[install.Dir] {
+			continue
+		}
+		seen[install.Dir] = true
+		installs = append(installs, install)
+	}
+	return installs
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Returns whether the directory holds an osu! install
+func isInstallDir(dir string) bool {
+	for _, name := range []string{clientExecutable, osuDbFile, cfgFile} {
+		if exists(filepath.Join(dir, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the install in the Wine prefix, or "" if there is none
+func findInstallInPrefix(prefix string) (string, error) {
+	for _, candidate := range installCandidates {
+		matches, err := filepath.Glob(filepath.Join(prefix, candidate))
+		if err != nil {
+			return "", err
+		}
+		for _, match := range matches {
+			if isInstallDir(match) {
+				return match, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// Returns the Wine prefix the directory is in, or "" if it is not in one
+func winePrefixOf(dir string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		if filepath.Base(d) == "drive_c" {
+			return filepath.Dir(d)
+		}
+		if filepath.Dir(d) == d {
+			return ""
+		}
+	}
+}
+
+// Returns the osu!.<user>.cfg in the directory, or "" if there is none. With
+// several, the one of the current user is preferred.
+func findUserCfg(dir string) (string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "osu!.*.cfg"))
+	if err != nil || len(matches) == 0 {
+		return "", err
+	}
+	sort.Strings(matches)
+	if current, err := user.Current(); err == nil {
+		name := current.Username
+		if i := strings.LastIndex(name, `\`); i >= 0 {
+			// Windows user names include the domain
+			name = name[i+1:]
+		}
+		for _, match := range matches {
+			if strings.EqualFold(filepath.Base(match), "osu!."+name+".cfg") {
+				return match, nil
+			}
+		}
+	}
+	return matches[0], nil
+}
+
+// Convert a path from the cfg to a local path. Relative paths are relative
+// to the install. Inside a Wine prefix, windows paths are mapped to the
+// prefix's drives.
+func (this *Install) localPath(path string) string {
+	if this.WinePrefix != "" && len(path) >= 2 && path[1] == ':' {
+		drive := strings.ToLower(path[:1])
+		rest := fromOsuPath(strings.TrimLeft(path[2:], `\/`))
+		if drive == "c" {
+			return filepath.Join(this.WinePrefix, "drive_c", rest)
+		}
+		return filepath.Join(this.WinePrefix, "dosdevices", drive+":", rest)
+	}
+	path = fromOsuPath(path)
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(this.Dir, path)
+}
+
+// Returns the osu!.db of the install, decoding it on first use
+func (this *Install) OsuDb() (*OsuDb, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.osuDb == nil {
+		db := &OsuDb{}
+		if err := this.decode(this.OsuDbPath, osuDbFile, db); err != nil {
+			return nil, err
+		}
+		this.osuDb = db
+	}
+	return this.osuDb, nil
+}
+
+// Returns the collection.db of the install, decoding it on first use
+func (this *Install) CollectionDb() (*CollectionDb, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.collectionDb == nil {
+		db := &CollectionDb{}
+		if err := this.decode(this.CollectionDbPath, collectionDbFile, db); err != nil {
+			return nil, err
+		}
+		this.collectionDb = db
+	}
+	return this.collectionDb, nil
+}
+
+// Returns the scores.db of the install, decoding it on first use
+func (this *Install) ScoresDb() (*ScoresDb, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.scoresDb == nil {
+		db := &ScoresDb{}
+		if err := this.decode(this.ScoresDbPath, scoresDbFile, db); err != nil {
+			return nil, err
+		}
+		this.scoresDb = db
+	}
+	return this.scoresDb, nil
+}
+
+// Returns the presence.db of the install, decoding it on first use
+func (this *Install) PresenceDb() (*PresenceDb, error) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	if this.presenceDb == nil {
+		db := &PresenceDb{}
+		if err := this.decode(this.PresenceDbPath, presenceDbFile, db); err != nil {
+			return nil, err
+		}
+		this.presenceDb = db
+	}
+	return this.presenceDb, nil
+}
+
+// Decode the DB at the path. The client may rewrite its DBs at any time, so
+// they are read into memory instead of being mapped like DecodeFile does.
+func (this *Install) decode(path string, name string, db interface{}) error {
+	if path == "" {
+		return fmt.Errorf("The install in %s has no %s", this.Dir, name)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	version, err := versionOfData(db, data)
+	if err == nil {
+		decoder := NewDecoder(data, version)
+		decoder.InternStrings = true
+		err = decoder.Decode(db)
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to decode %s: %v", path, err)
+	}
+	return nil
+}
+
+// Returns osu!.cfg of the install
+func (this *Install) Cfg() (*Cfg, error) {
+	if this.CfgPath == "" {
+		return nil, fmt.Errorf("The install in %s has no %s", this.Dir, cfgFile)
+	}
+	return ReadCfg(this.CfgPath)
+}
+
+// Returns the osu!.<user>.cfg of the install
+func (this *Install) UserCfg() (*Cfg, error) {
+	if this.UserCfgPath == "" {
+		return nil, fmt.Errorf("The install in %s has no user cfg", this.Dir)
+	}
+	return ReadCfg(this.UserCfgPath)
+}
+
+// Resolve the scores of the install's scores.db to their replay files, see
+// ResolveReplays.
+func (this *Install) Replays() ([]ReplayLink, error) {
+	scores, err := this.ScoresDb()
+	if err != nil {
+		return nil, err
+	}
+	return ResolveReplays(this.Dir, scores), nil
+}
+
+// Returns the .osr files in the install's replay folder
+func (this *Install) ReplayFiles() ([]string, error) {
+	if this.ReplayDir == "" {
+		return nil, nil
+	}
+	infos, err := ioutil.ReadDir(this.ReplayDir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, info := range infos {
+		if !info.IsDir() && strings.EqualFold(filepath.Ext(info.Name()), ".osr") {
+			files = append(files, filepath.Join(this.ReplayDir, info.Name()))
+		}
+	}
+	return files, nil
+}
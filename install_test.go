@@ -0,0 +1,84 @@
+package gosu
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenInstall(t *testing.T) {
+	install, err := OpenInstall("data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if install.SongsDir != filepath.Join(install.Dir, "Songs") || install.WinePrefix != "" ||
+		install.UserCfgPath != "" || install.ScoresDbPath == "" {
+		t.Errorf("Unexpected install %+v", install)
+	}
+	db, err := install.OsuDb()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := install.OsuDb()
+	if err != nil || again != db || len(db.Beatmaps) != 1702 {
+		t.Errorf("Expected the same decoded osu!.db, got %p %p %v", db, again, err)
+	}
+}
+
+func TestOpenInstallInWinePrefix(t *testing.T) {
+	prefix, err := ioutil.TempDir("", "gosu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(prefix)
+
+	dir := filepath.Join(prefix, "drive_c", "users", "player", "AppData", "Local", "osu!")
+	if err := os.MkdirAll(filepath.Join(dir, "Data", "r"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile("data/collection.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"collection.db":     string(data),
+		"osu!.cfg":          "# osu! configuration\r\n",
+		"osu!.player.cfg":   "BeatmapDirectory = D:\\osu\\Songs\r\n",
+		"Data/r/replay.osr": "",
+		"Data/r/notes.txt":  "",
+	}
+	for name, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	install, err := OpenInstall(prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if install.Dir != dir || install.WinePrefix != prefix {
+		t.Errorf("Expected the install in %s, got %+v", dir, install)
+	}
+	if expected := filepath.Join(prefix, "dosdevices", "d:", "osu", "Songs"); install.SongsDir != expected {
+		t.Errorf("Expected the songs in %s, got %s", expected, install.SongsDir)
+	}
+	if install.OsuDbPath != "" || install.CollectionDbPath == "" {
+		t.Errorf("Unexpected DB paths %+v", install)
+	}
+	if _, err := install.OsuDb(); err == nil {
+		t.Error("Expected decoding the missing osu!.db to fail")
+	}
+	collections, err := install.CollectionDb()
+	if err != nil || len(collections.Collections) == 0 {
+		t.Errorf("Expected the collections, got %v", err)
+	}
+	replays, err := install.ReplayFiles()
+	if err != nil || len(replays) != 1 {
+		t.Errorf("Expected a single replay, got %v %v", replays, err)
+	}
+	if _, err := OpenInstall(filepath.Join(prefix, "drive_c")); err == nil {
+		t.Error("Expected a directory without an install to fail")
+	}
+}
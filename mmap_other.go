@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd
+
+package gosu
+
+import "io/ioutil"
+
+// Read the whole file into memory. Memory mapping is not supported on this
+// platform.
+func MapFile(path string) (*MappedFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &MappedFile{Data: data}, nil
+}
@@ -0,0 +1,43 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+// +build linux darwin freebsd netbsd openbsd
+
+package gosu
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeMappedTruncated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "presence.db")
+	data, err := ioutil.ReadFile("data/presence.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mapped, err := MapFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mapped.Close()
+	// Another process truncating the file makes the mapping fault
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	err = decodeMapped(func() error {
+		return NewDecoder(mapped.Data, Int(20171227)).Decode(new(PresenceDb))
+	})
+	if err == nil {
+		t.Error("Expected decoding a truncated mapping to fail")
+	}
+}
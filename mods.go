@@ -0,0 +1,112 @@
+package gosu
+
+// The bit flags stored in the Mods field of scores and replays
+type Mods uint32
+
+const (
+	ModNoFail      Mods = 1 << 0
+	ModEasy        Mods = 1 << 1
+	ModTouchDevice Mods = 1 << 2
+	ModHidden      Mods = 1 << 3
+	ModHardRock    Mods = 1 << 4
+	ModSuddenDeath Mods = 1 << 5
+	ModDoubleTime  Mods = 1 << 6
+	ModRelax       Mods = 1 << 7
+	ModHalfTime    Mods = 1 << 8
+	ModNightcore   Mods = 1 << 9
+	ModFlashlight  Mods = 1 << 10
+	ModAutoplay    Mods = 1 << 11
+	ModSpunOut     Mods = 1 << 12
+	ModAutopilot   Mods = 1 << 13
+	ModPerfect     Mods = 1 << 14
+	ModKey4        Mods = 1 << 15
+	ModKey5        Mods = 1 << 16
+	ModKey6        Mods = 1 << 17
+	ModKey7        Mods = 1 << 18
+	ModKey8        Mods = 1 << 19
+	ModFadeIn      Mods = 1 << 20
+	ModRandom      Mods = 1 << 21
+	ModCinema      Mods = 1 << 22
+	ModTarget      Mods = 1 << 23
+	ModKey9        Mods = 1 << 24
+	ModKeyCoop     Mods = 1 << 25
+	ModKey1        Mods = 1 << 26
+	ModKey3        Mods = 1 << 27
+	ModKey2        Mods = 1 << 28
+	ModScoreV2     Mods = 1 << 29
+	ModMirror      Mods = 1 << 30
+)
+
+var modNames = []struct {
+	Mod  Mods
+	Name string
+}{
+	{ModNoFail, "NF"},
+	{ModEasy, "EZ"},
+	{ModTouchDevice, "TD"},
+	{ModHidden, "HD"},
+	{ModHardRock, "HR"},
+	{ModSuddenDeath, "SD"},
+	{ModDoubleTime, "DT"},
+	{ModRelax, "RX"},
+	{ModHalfTime, "HT"},
+	{ModNightcore, "NC"},
+	{ModFlashlight, "FL"},
+	{ModAutoplay, "AT"},
+	{ModSpunOut, "SO"},
+	{ModAutopilot, "AP"},
+	{ModPerfect, "PF"},
+	{ModKey4, "4K"},
+	{ModKey5, "5K"},
+	{ModKey6, "6K"},
+	{ModKey7, "7K"},
+	{ModKey8, "8K"},
+	{ModFadeIn, "FI"},
+	{ModRandom, "RD"},
+	{ModCinema, "CN"},
+	{ModTarget, "TP"},
+	{ModKey9, "9K"},
+	{ModKeyCoop, "CO"},
+	{ModKey1, "1K"},
+	{ModKey3, "3K"},
+	{ModKey2, "2K"},
+	{ModScoreV2, "V2"},
+	{ModMirror, "MR"},
+}
+
+// Returns true if all the given mods are enabled
+func (this Mods) Has(mods Mods) bool {
+	return this&mods == mods
+}
+
+// Returns the short names of the enabled mods, e.g. "HDDT". Nightcore and
+// Perfect imply DoubleTime and SuddenDeath, which are not repeated.
+func (this Mods) String() string {
+	if this.Has(ModNightcore) {
+		this &^= ModDoubleTime
+	}
+	if this.Has(ModPerfect) {
+		this &^= ModSuddenDeath
+	}
+	name := ""
+	for _, mod := range modNames {
+		if this.Has(mod.Mod) {
+			name += mod.Name
+		}
+	}
+	if name == "" {
+		return "NM"
+	}
+	return name
+}
+
+// Returns the speed at which the song is played
+func (this Mods) ClockRate() float64 {
+	switch {
+	case this.Has(ModDoubleTime), this.Has(ModNightcore):
+		return 1.5
+	case this.Has(ModHalfTime):
+		return 0.75
+	}
+	return 1
+}
@@ -0,0 +1,250 @@
+package gosu
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Random access to the beatmaps of a large osu!.db. An index of where each
+// beatmap starts is built once and kept in a sidecar file next to the DB,
+// so a single beatmap can be decoded without decoding the ones before it.
+
+// The version of the sidecar index format. Bump it whenever OsuDbIndex changes.
+const osuDbIndexVersion = Int(1)
+
+// The file extension of the sidecar index
+const osuDbIndexExtension = ".idx"
+
+// Where a beatmap starts in osu!.db
+//
+//gosu:codec
+type OsuDbIndexEntry struct {
+	// The position of the beatmap's SizeOfBeatmapBytes field
+	Offset Long
+	Md5    String
+}
+
+// The position of every beatmap in osu!.db
+//
+//gosu:codec
+type OsuDbIndex struct {
+	// The version of the index format
+	IndexVersion Int
+	// The version of the osu!.db
+	Version Int
+	// The size in bytes and the modification time in unix nanoseconds of the
+	// osu!.db the index was built from. The index is rebuilt when they change.
+	DbSize     Long
+	DbModTime  Long
+	NumEntries Int
+	Entries    []OsuDbIndexEntry
+}
+
+// The positions of the fields of OsuDb and BeatMap needed for partial decoding
+var (
+	osuDbBeatmapsField = fieldIndex(OsuDb{}, "Beatmaps")
+	osuDbNumFields     = reflect.TypeOf(OsuDb{}).NumField()
+	beatMapMd5Field    = fieldIndex(BeatMap{}, "Md5")
+	beatMapNumFields   = reflect.TypeOf(BeatMap{}).NumField()
+)
+
+func fieldIndex(db interface{}, name string) int {
+	field, ok := reflect.TypeOf(db).FieldByName(name)
+	if !ok {
+		panic(fmt.Sprintf("%T has no field %s", db, name))
+	}
+	return field.Index[0]
+}
+
+// Build the index of the osu!.db data. The beatmaps are found by following
+// their SizeOfBeatmapBytes fields. If the sizes do not add up, every beatmap
+// is decoded instead to find where it ends.
+// Args:
+//   data: The contents of an osu!.db
+func BuildOsuDbIndex(data []byte) (*OsuDbIndex, error) {
+	version, err := versionOfData(&OsuDb{}, data)
+	if err != nil {
+		return nil, err
+	}
+	_, entries, err := scanBeatmaps(data, version, true)
+	if err != nil {
+		_, entries, err = scanBeatmaps(data, version, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &OsuDbIndex{
+		IndexVersion: osuDbIndexVersion,
+		Version:      version,
+		DbSize:       Long(len(data)),
+		NumEntries:   Int(len(entries)),
+		Entries:      entries,
+	}, nil
+}
+
+// Decode the header of the osu!.db and find where each beatmap starts.
+// The header is returned without beatmaps.
+// Args:
+//   data: The contents of an osu!.db
+//   version: The version of the osu!.db
+//   useSizes: If true, jump over each beatmap using its SizeOfBeatmapBytes
+//     instead of decoding it
+func scanBeatmaps(data []byte, version Int, useSizes bool) (*OsuDb, []OsuDbIndexEntry, error) {
+	db := &OsuDb{}
+	decoder := NewDecoder(data, version)
+	if err := decoder.decodeFields(db, 0, osuDbBeatmapsField); err != nil {
+		return nil, nil, err
+	}
+
+	entries := make([]OsuDbIndexEntry, 0, db.NumBeatmaps)
+	var beatmap BeatMap
+	for i := 0; i < int(db.NumBeatmaps); i++ {
+		offset := decoder.Offset()
+		if err := decoder.decodeFields(&beatmap, 0, beatMapMd5Field+1); err != nil {
+			return nil, nil, fmt.Errorf("Beatmaps[%d]: %v", i, err)
+		}
+		entries = append(entries, OsuDbIndexEntry{Long(offset), beatmap.Md5})
+
+		if useSizes {
+			if err := decoder.Seek(offset + 4 + int(beatmap.SizeOfBeatmapBytes)); err != nil {
+				return nil, nil, fmt.Errorf("Beatmaps[%d]: %v", i, err)
+			}
+		} else if err := decoder.decodeFields(&beatmap, beatMapMd5Field+1, beatMapNumFields); err != nil {
+			return nil, nil, fmt.Errorf("Beatmaps[%d]: %v", i, err)
+		}
+	}
+
+	if err := decoder.decodeFields(db, osuDbBeatmapsField+1, osuDbNumFields); err != nil {
+		return nil, nil, err
+	}
+	if decoder.Offset() != len(data) {
+		return nil, nil, fmt.Errorf("%d trailing bytes after the beatmaps",
+			len(data)-decoder.Offset())
+	}
+	return db, entries, nil
+}
+
+// Returns the path of the sidecar index of the osu!.db at the path
+func OsuDbIndexPath(dbPath string) string {
+	return dbPath + osuDbIndexExtension
+}
+
+// An osu!.db opened for random access to its beatmaps, see OpenOsuDb
+type OsuDbReader struct {
+	Index  *OsuDbIndex
+	path   string
+	mapped *MappedFile
+	byMd5  map[string]int
+}
+
+// Open the osu!.db at the path for random access. The index is loaded from
+// the sidecar file if it is still up to date with the DB, otherwise it is
+// built and saved to the sidecar. Failing to save the sidecar is not an
+// error, the index is then rebuilt the next time. The DB stays memory mapped
+// until the reader is closed. The client rewrites osu!.db when it exits, so
+// once the file changes the reader fails to decode and must be opened again.
+// Args:
+//   path: The path of the osu!.db
+func OpenOsuDb(path string) (*OsuDbReader, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	mapped, err := MapFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	index := loadOsuDbIndex(OsuDbIndexPath(path), info)
+	if index == nil {
+		index, err = BuildOsuDbIndex(mapped.Data)
+		if err != nil {
+			mapped.Close()
+			return nil, err
+		}
+		index.DbModTime = Long(info.ModTime().UnixNano())
+		var buf bytes.Buffer
+		if err := index.MarshalOsuBinary(&buf, osuDbIndexVersion); err == nil {
+			ioutil.WriteFile(OsuDbIndexPath(path), buf.Bytes(), 0644)
+		}
+	}
+
+	reader := &OsuDbReader{Index: index, path: path, mapped: mapped, byMd5: make(map[string]int)}
+	for i, entry := range index.Entries {
+		reader.byMd5[strings.ToLower(entry.Md5.Text)] = i
+	}
+	return reader, nil
+}
+
+// Returns the index in the sidecar file, or nil if it is missing, corrupt or
+// out of date with the DB.
+func loadOsuDbIndex(indexPath string, dbInfo os.FileInfo) *OsuDbIndex {
+	data, err := ioutil.ReadFile(indexPath)
+	if err != nil {
+		return nil
+	}
+	index := &OsuDbIndex{}
+	if err := NewDecoder(data, osuDbIndexVersion).Decode(index); err != nil {
+		return nil
+	}
+	if index.IndexVersion != osuDbIndexVersion ||
+		index.DbSize != Long(dbInfo.Size()) ||
+		index.DbModTime != Long(dbInfo.ModTime().UnixNano()) {
+		return nil
+	}
+	for _, entry := range index.Entries {
+		if entry.Offset >= index.DbSize {
+			return nil
+		}
+	}
+	return index
+}
+
+// Returns the number of beatmaps in the DB
+func (this *OsuDbReader) Len() int {
+	return len(this.Index.Entries)
+}
+
+// Decode the i-th beatmap of the DB
+func (this *OsuDbReader) Beatmap(i int) (*BeatMap, error) {
+	if i < 0 || i >= len(this.Index.Entries) {
+		return nil, fmt.Errorf("Beatmap %d is out of range [0, %d)", i, len(this.Index.Entries))
+	}
+	info, err := os.Stat(this.path)
+	if err != nil {
+		return nil, err
+	}
+	if Long(info.Size()) != this.Index.DbSize || Long(info.ModTime().UnixNano()) != this.Index.DbModTime {
+		return nil, fmt.Errorf("%s has changed since it was opened", this.path)
+	}
+
+	decoder := NewDecoder(this.mapped.Data, this.Index.Version)
+	if err := decoder.Seek(int(this.Index.Entries[i].Offset)); err != nil {
+		return nil, err
+	}
+	beatmap := &BeatMap{}
+	// The file can still be truncated between the check and the decode
+	if err := decodeMapped(func() error { return decoder.Decode(beatmap) }); err != nil {
+		return nil, fmt.Errorf("Beatmaps[%d]: %v", i, err)
+	}
+	return beatmap, nil
+}
+
+// Decode the beatmap with the given MD5 hash. Returns nil if the DB has no
+// such beatmap.
+func (this *OsuDbReader) BeatmapByMd5(md5 string) (*BeatMap, error) {
+	i, ok := this.byMd5[strings.ToLower(md5)]
+	if !ok {
+		return nil, nil
+	}
+	return this.Beatmap(i)
+}
+
+// Release the DB. Beatmaps decoded before stay valid.
+func (this *OsuDbReader) Close() error {
+	return this.mapped.Close()
+}
@@ -0,0 +1,99 @@
+package gosu
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/d4l3k/messagediff"
+)
+
+func TestOpenOsuDb(t *testing.T) {
+	data, err := ioutil.ReadFile("data/osu!.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "gosu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "osu!.db")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := new(OsuDb)
+	if err := NewDecoder(data, Int(20171227)).Decode(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first open builds the sidecar index, the second one loads it
+	for _, sidecar := range []bool{false, true} {
+		if _, err := os.Stat(OsuDbIndexPath(path)); (err == nil) != sidecar {
+			t.Fatalf("Expected the sidecar to exist: %v, got %v", sidecar, err)
+		}
+		reader, err := OpenOsuDb(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reader.Len() != len(db.Beatmaps) {
+			t.Fatalf("Expected %d beatmaps, got %d", len(db.Beatmaps), reader.Len())
+		}
+		for _, i := range []int{0, 1, 700, len(db.Beatmaps) - 1} {
+			beatmap, err := reader.Beatmap(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff, equal := messagediff.PrettyDiff(&db.Beatmaps[i], beatmap); !equal {
+				t.Errorf("Beatmap %d differs.\n%s", i, diff)
+			}
+		}
+		beatmap, err := reader.BeatmapByMd5(db.Beatmaps[42].Md5.Text)
+		if err != nil || beatmap == nil || beatmap.Md5 != db.Beatmaps[42].Md5 {
+			t.Errorf("Expected to find beatmap 42 by its md5, got %v %v", beatmap, err)
+		}
+		if _, err := reader.Beatmap(reader.Len()); err == nil {
+			t.Error("Expected an out of range beatmap to fail")
+		}
+		reader.Close()
+	}
+
+	// Once the DB is rewritten the reader refuses to decode from it
+	reader, err := OpenOsuDb(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if err := os.Truncate(path, int64(len(data)/2)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reader.Beatmap(reader.Len() - 1); err == nil {
+		t.Error("Expected decoding a truncated DB to fail")
+	}
+}
+
+func TestBuildOsuDbIndexWithoutSizes(t *testing.T) {
+	data, err := ioutil.ReadFile("data/osu!.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, err := BuildOsuDbIndex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt the size of the first beatmap, the index falls back to decoding
+	// every beatmap and finds the same offsets.
+	corrupt := append([]byte(nil), data...)
+	offset := index.Entries[0].Offset
+	corrupt[offset] ^= 0xff
+	scanned, err := BuildOsuDbIndex(corrupt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff, equal := messagediff.PrettyDiff(index, scanned); !equal {
+		t.Errorf("The scanned index differs.\n%s", diff)
+	}
+}
@@ -0,0 +1,104 @@
+package gosu
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// The number of chunks per worker, so that a worker which gets the chunks
+// with the larger beatmaps does not hold up the others.
+const chunksPerWorker = 4
+
+// Decode osu!.db across several goroutines. The beatmaps are split into
+// chunks using their SizeOfBeatmapBytes fields and each chunk is decoded on
+// its own. If the sizes do not add up, the boundaries of the beatmaps are
+// found by a sequential scan instead and the beatmaps are still decoded in
+// parallel. The beatmaps keep their order, and when several beatmaps fail to
+// decode the error is always the one of the first.
+// Args:
+//   db: The DB to decode into
+//   data: The contents of an osu!.db
+//   version: The version of the osu!.db
+//   workers: The number of goroutines, or 0 for one per CPU
+func DecodeOsuDbParallel(db *OsuDb, data []byte, version Int, workers int) error {
+	header, entries, err := scanBeatmaps(data, version, true)
+	// The sizes are only checked against the decoded beatmaps if the
+	// boundaries come from them
+	checkSizes := err == nil
+	if err != nil {
+		header, entries, err = scanBeatmaps(data, version, false)
+	}
+	if err != nil {
+		return err
+	}
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	beatmaps := make([]BeatMap, len(entries))
+	numChunks := workers * chunksPerWorker
+	chunkSize := (len(entries) + numChunks - 1) / numChunks
+	if chunkSize == 0 {
+		chunkSize = 1
+	}
+	var chunks [][2]int
+	for start := 0; start < len(entries); start += chunkSize {
+		end := start + chunkSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+		chunks = append(chunks, [2]int{start, end})
+	}
+
+	errs := make([]error, len(chunks))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range work {
+				errs[chunk] = decodeBeatmaps(data, version, entries, beatmaps,
+					chunks[chunk][0], chunks[chunk][1], checkSizes)
+			}
+		}()
+	}
+	for chunk := range chunks {
+		work <- chunk
+	}
+	close(work)
+	wg.Wait()
+
+	// Chunks are in order and stop at their first error, so this is the
+	// error of the first beatmap which failed.
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	*db = *header
+	db.Beatmaps = beatmaps
+	return nil
+}
+
+// Decode the beatmaps [start, end) into beatmaps. With checkSizes, a
+// beatmap which does not decode to its SizeOfBeatmapBytes is an error.
+func decodeBeatmaps(data []byte, version Int, entries []OsuDbIndexEntry, beatmaps []BeatMap,
+	start int, end int, checkSizes bool) error {
+	decoder := NewDecoder(data, version)
+	for i := start; i < end; i++ {
+		offset := int(entries[i].Offset)
+		if err := decoder.Seek(offset); err != nil {
+			return fmt.Errorf("Beatmaps[%d]: %v", i, err)
+		}
+		if err := decoder.Decode(&beatmaps[i]); err != nil {
+			return fmt.Errorf("Beatmaps[%d]: %v", i, err)
+		}
+		if size := decoder.Offset() - offset - 4; checkSizes && size != int(beatmaps[i].SizeOfBeatmapBytes) {
+			return fmt.Errorf("Beatmaps[%d]: decoded %d bytes but SizeOfBeatmapBytes is %d",
+				i, size, beatmaps[i].SizeOfBeatmapBytes)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package gosu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/d4l3k/messagediff"
+)
+
+func TestDecodeOsuDbParallel(t *testing.T) {
+	data, err := ioutil.ReadFile("data/osu!.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := new(OsuDb)
+	if err := NewDecoder(data, Int(20171227)).Decode(want); err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{0, 1, 3} {
+		db := new(OsuDb)
+		if err := DecodeOsuDbParallel(db, data, Int(20171227), workers); err != nil {
+			t.Fatal(err)
+		}
+		if diff, equal := messagediff.PrettyDiff(want, db); !equal {
+			t.Errorf("%d workers decoded different values.\n%s", workers, diff)
+		}
+	}
+
+	// Two beatmaps with a wrong timing point count decode to a different size
+	// than their SizeOfBeatmapBytes, the first of them is reported.
+	index, err := BuildOsuDbIndex(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupt := append([]byte(nil), data...)
+	for _, i := range []int{100, 1500} {
+		decoder := NewDecoder(data, Int(20171227))
+		if err := decoder.Seek(int(index.Entries[i].Offset)); err != nil {
+			t.Fatal(err)
+		}
+		if err := decoder.decodeFields(new(BeatMap), 0, fieldIndex(BeatMap{}, "NumTimingPoints")); err != nil {
+			t.Fatal(err)
+		}
+		count := corrupt[decoder.Offset():]
+		binary.LittleEndian.PutUint32(count, binary.LittleEndian.Uint32(count)+1)
+	}
+	for _, workers := range []int{1, 8} {
+		err := DecodeOsuDbParallel(new(OsuDb), corrupt, Int(20171227), workers)
+		if err == nil || !strings.HasPrefix(err.Error(), "Beatmaps[100]:") {
+			t.Errorf("Expected beatmap 100 to fail with %d workers, got %v", workers, err)
+		}
+	}
+
+	// With a wrong SizeOfBeatmapBytes the beatmaps are found by scanning
+	corrupt = append([]byte(nil), data...)
+	size := corrupt[index.Entries[100].Offset:]
+	binary.LittleEndian.PutUint32(size, binary.LittleEndian.Uint32(size)+1)
+	want = new(OsuDb)
+	if err := NewDecoder(corrupt, Int(20171227)).Decode(want); err != nil {
+		t.Fatal(err)
+	}
+	db := new(OsuDb)
+	if err := DecodeOsuDbParallel(db, corrupt, Int(20171227), 3); err != nil {
+		t.Fatal(err)
+	}
+	if diff, equal := messagediff.PrettyDiff(want, db); !equal {
+		t.Errorf("Decoded different values with a wrong size.\n%s", diff)
+	}
+}
+
+// Compares the parallel decode with UnmarshalAny and the sequential Decoder
+func BenchmarkDecodeOsuDbParallel(b *testing.B) {
+	b.Run("UnmarshalAny", func(b *testing.B) {
+		benchmarkOsuDb(b, func(data []byte) error {
+			return UnmarshalAny(new(OsuDb), bytes.NewReader(data), Int(20171227))
+		})
+	})
+	b.Run("Decoder", func(b *testing.B) {
+		benchmarkOsuDb(b, func(data []byte) error {
+			return NewDecoder(data, Int(20171227)).Decode(new(OsuDb))
+		})
+	})
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("Workers%d", workers), func(b *testing.B) {
+			benchmarkOsuDb(b, func(data []byte) error {
+				return DecodeOsuDbParallel(new(OsuDb), data, Int(20171227), workers)
+			})
+		})
+	}
+}
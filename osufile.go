@@ -0,0 +1,444 @@
+package gosu
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Parser for the plain-text .osu beatmap format.
+// See https://github.com/ppy/osu-wiki/blob/master/wiki/osu!_File_Formats/Osu_(file_format)/en.md
+// for the spec of these sections. Only the sections needed to derive the
+// fields stored in osu!.db (and to analyse the hit objects) are parsed; all
+// other sections are skipped.
+
+// Bit flags of the HitObject.Type field
+const (
+	HitObjectCircle   = 1 << 0
+	HitObjectSlider   = 1 << 1
+	HitObjectNewCombo = 1 << 2
+	HitObjectSpinner  = 1 << 3
+	HitObjectHold     = 1 << 7
+)
+
+type OsuFile struct {
+	FormatVersion int
+
+	// [General]
+	AudioFilename  string
+	AudioLeadIn    int
+	PreviewTime    int
+	StackLeniency  float64
+	Mode           GameMode
+	LetterboxBreak bool
+
+	// [Metadata]
+	Title         string
+	TitleUnicode  string
+	Artist        string
+	ArtistUnicode string
+	Creator       string
+	Version       string
+	Source        string
+	Tags          string
+	BeatmapID     int
+	BeatmapSetID  int
+
+	// [Difficulty]
+	HPDrainRate       float64
+	CircleSize        float64
+	OverallDifficulty float64
+	ApproachRate      float64
+	SliderMultiplier  float64
+	SliderTickRate    float64
+
+	// [Events]
+	Breaks []OsuFileBreak
+
+	// [TimingPoints]
+	TimingPoints []OsuFileTimingPoint
+
+	// [HitObjects]
+	HitObjects []HitObject
+}
+
+type OsuFileBreak struct {
+	StartMsec int
+	EndMsec   int
+}
+
+type OsuFileTimingPoint struct {
+	OffsetMsec  float64
+	BeatLength  float64
+	Meter       int
+	SampleSet   int
+	SampleIndex int
+	Volume      int
+	Uninherited bool
+	Effects     int
+}
+
+type HitObject struct {
+	X        int
+	Y        int
+	TimeMsec int
+	Type     int
+	HitSound int
+
+	// Only populated for sliders
+	CurveType   byte
+	CurvePoints [][2]int
+	Slides      int
+	Length      float64
+
+	// Only populated for spinners and mania hold notes
+	EndTimeMsec int
+}
+
+func (this *HitObject) IsCircle() bool  { return this.Type&HitObjectCircle != 0 }
+func (this *HitObject) IsSlider() bool  { return this.Type&HitObjectSlider != 0 }
+func (this *HitObject) IsSpinner() bool { return this.Type&HitObjectSpinner != 0 }
+func (this *HitObject) IsHold() bool    { return this.Type&HitObjectHold != 0 }
+
+// Read and parse the .osu file found at the given path
+func ReadOsuFile(path string) (*OsuFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseOsuFile(file)
+}
+
+// Parse a .osu file from the given reader.
+// Unknown sections and keys are ignored. An error is returned if the file
+// does not start with the "osu file format" header or if a line in one of the
+// parsed sections is malformed.
+func ParseOsuFile(buf io.Reader) (*OsuFile, error) {
+	osu := &OsuFile{
+		StackLeniency:    0.7,
+		SliderMultiplier: 1.4,
+		SliderTickRate:   1,
+		ApproachRate:     -1,
+	}
+
+	scanner := bufio.NewScanner(buf)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	section := ""
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if lineNum == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+			if !strings.HasPrefix(line, "osu file format v") {
+				return nil, errors.New("Missing osu file format header")
+			}
+			version, err := strconv.Atoi(strings.TrimPrefix(line, "osu file format v"))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid osu file format header %q", line)
+			}
+			osu.FormatVersion = version
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = line[1 : len(line)-1]
+			continue
+		}
+
+		var err error
+		switch section {
+		case "General", "Metadata", "Difficulty":
+			err = osu.parseKeyValue(line)
+		case "Events":
+			err = osu.parseEvent(line)
+		case "TimingPoints":
+			err = osu.parseTimingPoint(line)
+		case "HitObjects":
+			err = osu.parseHitObject(line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if lineNum == 0 {
+		return nil, errors.New("Missing osu file format header")
+	}
+
+	// Older beatmaps did not have a separate approach rate
+	if osu.ApproachRate < 0 {
+		osu.ApproachRate = osu.OverallDifficulty
+	}
+	return osu, nil
+}
+
+func (this *OsuFile) parseKeyValue(line string) error {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("Expected key:value pair, got %q", line)
+	}
+	key := strings.TrimSpace(parts[0])
+	value := strings.TrimSpace(parts[1])
+
+	var err error
+	switch key {
+	case "AudioFilename":
+		this.AudioFilename = value
+	case "AudioLeadIn":
+		this.AudioLeadIn, err = strconv.Atoi(value)
+	case "PreviewTime":
+		this.PreviewTime, err = strconv.Atoi(value)
+	case "StackLeniency":
+		this.StackLeniency, err = strconv.ParseFloat(value, 64)
+	case "Mode":
+		var mode int
+		mode, err = strconv.Atoi(value)
+		this.Mode = GameMode(mode)
+	case "LetterboxInBreaks":
+		this.LetterboxBreak = value == "1"
+	case "Title":
+		this.Title = value
+	case "TitleUnicode":
+		this.TitleUnicode = value
+	case "Artist":
+		this.Artist = value
+	case "ArtistUnicode":
+		this.ArtistUnicode = value
+	case "Creator":
+		this.Creator = value
+	case "Version":
+		this.Version = value
+	case "Source":
+		this.Source = value
+	case "Tags":
+		this.Tags = value
+	case "BeatmapID":
+		this.BeatmapID, err = strconv.Atoi(value)
+	case "BeatmapSetID":
+		this.BeatmapSetID, err = strconv.Atoi(value)
+	case "HPDrainRate":
+		this.HPDrainRate, err = strconv.ParseFloat(value, 64)
+	case "CircleSize":
+		this.CircleSize, err = strconv.ParseFloat(value, 64)
+	case "OverallDifficulty":
+		this.OverallDifficulty, err = strconv.ParseFloat(value, 64)
+	case "ApproachRate":
+		this.ApproachRate, err = strconv.ParseFloat(value, 64)
+	case "SliderMultiplier":
+		this.SliderMultiplier, err = strconv.ParseFloat(value, 64)
+	case "SliderTickRate":
+		this.SliderTickRate, err = strconv.ParseFloat(value, 64)
+	}
+	if err != nil {
+		return fmt.Errorf("Invalid value for %s: %v", key, err)
+	}
+	return nil
+}
+
+func (this *OsuFile) parseEvent(line string) error {
+	parts := strings.Split(line, ",")
+	if len(parts) < 3 || (parts[0] != "2" && parts[0] != "Break") {
+		return nil
+	}
+	start, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return err
+	}
+	end, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+	if err != nil {
+		return err
+	}
+	this.Breaks = append(this.Breaks, OsuFileBreak{start, end})
+	return nil
+}
+
+func (this *OsuFile) parseTimingPoint(line string) error {
+	parts := strings.Split(line, ",")
+	if len(parts) < 2 {
+		return fmt.Errorf("Invalid timing point %q", line)
+	}
+
+	point := OsuFileTimingPoint{Meter: 4, Volume: 100, Uninherited: true}
+	var err error
+	if point.OffsetMsec, err = strconv.ParseFloat(parts[0], 64); err != nil {
+		return err
+	}
+	if point.BeatLength, err = strconv.ParseFloat(parts[1], 64); err != nil {
+		return err
+	}
+	ints := []*int{&point.Meter, &point.SampleSet, &point.SampleIndex, &point.Volume}
+	for i := 0; i < len(ints) && i+2 < len(parts); i++ {
+		if *ints[i], err = strconv.Atoi(parts[i+2]); err != nil {
+			return err
+		}
+	}
+	if len(parts) > 6 {
+		point.Uninherited = parts[6] == "1"
+	} else {
+		// Older formats mark inherited points only by a negative beat length
+		point.Uninherited = point.BeatLength >= 0
+	}
+	if len(parts) > 7 {
+		if point.Effects, err = strconv.Atoi(parts[7]); err != nil {
+			return err
+		}
+	}
+	this.TimingPoints = append(this.TimingPoints, point)
+	return nil
+}
+
+func (this *OsuFile) parseHitObject(line string) error {
+	parts := strings.Split(line, ",")
+	if len(parts) < 4 {
+		return fmt.Errorf("Invalid hit object %q", line)
+	}
+
+	var h HitObject
+	var err error
+	ints := []*int{&h.X, &h.Y, &h.TimeMsec, &h.Type}
+	for i := range ints {
+		// Some beatmaps contain decimal coordinates, truncate them like the client.
+		f, err := strconv.ParseFloat(parts[i], 64)
+		if err != nil {
+			return err
+		}
+		*ints[i] = int(f)
+	}
+	if len(parts) > 4 {
+		if h.HitSound, err = strconv.Atoi(parts[4]); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case h.IsSlider():
+		if len(parts) < 8 {
+			return fmt.Errorf("Invalid slider %q", line)
+		}
+		curve := strings.Split(parts[5], "|")
+		if len(curve[0]) > 0 {
+			h.CurveType = curve[0][0]
+		}
+		for _, point := range curve[1:] {
+			xy := strings.Split(point, ":")
+			if len(xy) != 2 {
+				return fmt.Errorf("Invalid slider point %q", point)
+			}
+			x, err := strconv.Atoi(xy[0])
+			if err != nil {
+				return err
+			}
+			y, err := strconv.Atoi(xy[1])
+			if err != nil {
+				return err
+			}
+			h.CurvePoints = append(h.CurvePoints, [2]int{x, y})
+		}
+		if h.Slides, err = strconv.Atoi(parts[6]); err != nil {
+			return err
+		}
+		if h.Length, err = strconv.ParseFloat(parts[7], 64); err != nil {
+			return err
+		}
+	case h.IsSpinner():
+		if len(parts) < 6 {
+			return fmt.Errorf("Invalid spinner %q", line)
+		}
+		if h.EndTimeMsec, err = strconv.Atoi(parts[5]); err != nil {
+			return err
+		}
+	case h.IsHold():
+		if len(parts) < 6 {
+			return fmt.Errorf("Invalid hold note %q", line)
+		}
+		end := strings.SplitN(parts[5], ":", 2)[0]
+		if h.EndTimeMsec, err = strconv.Atoi(end); err != nil {
+			return err
+		}
+	}
+
+	this.HitObjects = append(this.HitObjects, h)
+	return nil
+}
+
+// Returns the time (in msec) at which the hit object ends. For circles this
+// is the same as the start time.
+func (this *OsuFile) EndTimeOf(h *HitObject) int {
+	return this.endTimeOf(h, NewTimingMap(this.DbTimingPoints(), 0))
+}
+
+// EndTimeOf with the timing of the beatmap already built
+func (this *OsuFile) endTimeOf(h *HitObject, timing *TimingMap) int {
+	switch {
+	case h.IsSlider():
+		timeMsec := float64(h.TimeMsec)
+		pixelsPerBeat := this.SliderMultiplier * 100 * timing.SliderVelocityAt(timeMsec)
+		if pixelsPerBeat <= 0 {
+			return h.TimeMsec
+		}
+		duration := h.Length / pixelsPerBeat * timing.beatLengthOrDefault(timeMsec) * float64(h.Slides)
+		return h.TimeMsec + int(duration)
+	case h.IsSpinner(), h.IsHold():
+		return h.EndTimeMsec
+	}
+	return h.TimeMsec
+}
+
+// Returns the time (in msec) at which the last hit object ends, measured
+// from the start of the song. Returns 0 if there are no hit objects.
+func (this *OsuFile) TotalTimeMsec() int {
+	return this.totalTimeMsec(NewTimingMap(this.DbTimingPoints(), 0))
+}
+
+func (this *OsuFile) totalTimeMsec(timing *TimingMap) int {
+	if len(this.HitObjects) == 0 {
+		return 0
+	}
+	last := 0
+	for i := range this.HitObjects {
+		if end := this.endTimeOf(&this.HitObjects[i], timing); end > last {
+			last = end
+		}
+	}
+	return last
+}
+
+// Returns the playable time (in seconds) of the beatmap, which is the time
+// between the first and last hit object excluding breaks.
+func (this *OsuFile) DrainTimeSecs() int {
+	if len(this.HitObjects) == 0 {
+		return 0
+	}
+	drain := this.TotalTimeMsec() - this.HitObjects[0].TimeMsec
+	for _, b := range this.Breaks {
+		drain -= b.EndMsec - b.StartMsec
+	}
+	if drain < 0 {
+		return 0
+	}
+	return drain / 1000
+}
+
+// Convert the timing points into the form stored within osu!.db
+func (this *OsuFile) DbTimingPoints() []TimingPoint {
+	points := make([]TimingPoint, len(this.TimingPoints))
+	for i, point := range this.TimingPoints {
+		points[i].BPM = Double(point.BeatLength)
+		points[i].OffsetMsec = Double(point.OffsetMsec)
+		// osu!.db stores true for timing points which are NOT inherited.
+		if point.Uninherited {
+			points[i].IsInherited = 1
+		}
+	}
+	return points
+}
@@ -0,0 +1,259 @@
+package gosu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A profile-like summary of the local scores of a player
+
+// The default number of entries in the top lists of a PlayerReport
+const DefaultReportTopN = 10
+
+// The options of NewPlayerReport
+type PlayerReportOptions struct {
+	// Only include the scores set by this player (case insensitive). Every
+	// player's scores are included when empty.
+	PlayerName string
+	// The number of entries in the best scores, most played and top
+	// performance lists. Defaults to DefaultReportTopN.
+	TopN int
+	// The osu!.db of the install. Optional, it is used for the beatmap names
+	// and star ratings. Without it the pp of every score is 0.
+	OsuDb *OsuDb
+}
+
+// A single score in a PlayerReport
+type ReportScore struct {
+	BeatmapMd5 string    `json:"beatmap_md5"`
+	Beatmap    string    `json:"beatmap,omitempty"`
+	PlayerName string    `json:"player_name"`
+	Mode       string    `json:"mode"`
+	Mods       string    `json:"mods"`
+	Score      int       `json:"score"`
+	MaxCombo   int       `json:"max_combo"`
+	Accuracy   float64   `json:"accuracy"`
+	Grade      string    `json:"grade"`
+	PP         float64   `json:"pp"`
+	PlayedAt   time.Time `json:"played_at"`
+}
+
+// The number of local plays of a beatmap
+type MapPlayCount struct {
+	BeatmapMd5 string `json:"beatmap_md5"`
+	Beatmap    string `json:"beatmap,omitempty"`
+	Plays      int    `json:"plays"`
+}
+
+// The number of plays in a calendar month
+type MonthPlayCount struct {
+	// The month formatted as "2006-01"
+	Month string `json:"month"`
+	Plays int    `json:"plays"`
+}
+
+// The statistics of the local plays of a player, or of every player
+type PlayerReport struct {
+	PlayerName      string         `json:"player_name,omitempty"`
+	TotalPlays      int            `json:"total_plays"`
+	PlaysPerMode    map[string]int `json:"plays_per_mode"`
+	GradeCounts     map[string]int `json:"grade_counts"`
+	AverageAccuracy float64        `json:"average_accuracy"`
+	// The highest scoring play of each beatmap, best first
+	BestScores []ReportScore `json:"best_scores"`
+	// The beatmaps with the most plays, most played first
+	MostPlayed []MapPlayCount `json:"most_played"`
+	// The plays per month, in chronological order
+	PlaysOverTime []MonthPlayCount `json:"plays_over_time"`
+	// The plays with the highest estimated pp, see EstimatePP
+	TopPerformance []ReportScore `json:"top_performance"`
+}
+
+// Build the report of the scores in the scores.db
+// Args:
+//   scores: The decoded scores.db
+//   options: Which player to report on and where to find the beatmaps
+func NewPlayerReport(scores *ScoresDb, options PlayerReportOptions) *PlayerReport {
+	topN := options.TopN
+	if topN <= 0 {
+		topN = DefaultReportTopN
+	}
+	beatmaps := make(map[string]*BeatMap)
+	if options.OsuDb != nil {
+		for i := range options.OsuDb.Beatmaps {
+			beatmap := &options.OsuDb.Beatmaps[i]
+			beatmaps[beatmap.Md5.Text] = beatmap
+		}
+	}
+
+	report := &PlayerReport{
+		PlayerName:   options.PlayerName,
+		PlaysPerMode: make(map[string]int),
+		GradeCounts:  make(map[string]int),
+	}
+	months := make(map[string]int)
+	var plays []ReportScore
+	totalAccuracy := 0.0
+	for i := range scores.Beatmaps {
+		best := -1
+		numPlays := 0
+		for j := range scores.Beatmaps[i].Scores {
+			score := &scores.Beatmaps[i].Scores[j]
+			if options.PlayerName != "" &&
+				!strings.EqualFold(score.PlayerName.Text, options.PlayerName) {
+				continue
+			}
+			play := newReportScore(score, beatmaps[score.Md5Hash.Text])
+			plays = append(plays, play)
+			numPlays++
+
+			report.PlaysPerMode[play.Mode]++
+			report.GradeCounts[play.Grade]++
+			totalAccuracy += play.Accuracy
+			months[play.PlayedAt.Format("2006-01")]++
+			if best < 0 || play.Score > plays[best].Score {
+				best = len(plays) - 1
+			}
+		}
+		if numPlays == 0 {
+			continue
+		}
+		report.BestScores = append(report.BestScores, plays[best])
+		report.MostPlayed = append(report.MostPlayed, MapPlayCount{
+			BeatmapMd5: plays[best].BeatmapMd5,
+			Beatmap:    plays[best].Beatmap,
+			Plays:      numPlays,
+		})
+	}
+
+	report.TotalPlays = len(plays)
+	if report.TotalPlays > 0 {
+		report.AverageAccuracy = totalAccuracy / float64(report.TotalPlays)
+	}
+	for month, count := range months {
+		report.PlaysOverTime = append(report.PlaysOverTime, MonthPlayCount{month, count})
+	}
+	sort.Slice(report.PlaysOverTime, func(i, j int) bool {
+		return report.PlaysOverTime[i].Month < report.PlaysOverTime[j].Month
+	})
+
+	sort.SliceStable(report.BestScores, func(i, j int) bool {
+		return report.BestScores[i].Score > report.BestScores[j].Score
+	})
+	report.BestScores = truncateScores(report.BestScores, topN)
+
+	sort.SliceStable(report.MostPlayed, func(i, j int) bool {
+		return report.MostPlayed[i].Plays > report.MostPlayed[j].Plays
+	})
+	if len(report.MostPlayed) > topN {
+		report.MostPlayed = report.MostPlayed[:topN]
+	}
+
+	sort.SliceStable(plays, func(i, j int) bool {
+		return plays[i].PP > plays[j].PP
+	})
+	report.TopPerformance = truncateScores(plays, topN)
+	return report
+}
+
+func newReportScore(score *ScoresDbBeatMapScore, beatmap *BeatMap) ReportScore {
+	play := ReportScore{
+		BeatmapMd5: score.Md5Hash.Text,
+		PlayerName: score.PlayerName.Text,
+		Mode:       GameMode(score.GameplayMode).String(),
+		Mods:       Mods(score.Mods).String(),
+		Score:      int(score.ReplayScore),
+		MaxCombo:   int(score.MaxCombo),
+		Accuracy:   score.Accuracy(),
+		Grade:      score.Grade().String(),
+		PlayedAt:   TicksToTime(uint64(score.TimestampOfReplayWindowTicks)),
+	}
+	if beatmap != nil {
+		play.Beatmap = fmt.Sprintf("%s - %s [%s]",
+			beatmap.ArtistName.Text, beatmap.SongTitle.Text, beatmap.Difficulty.Text)
+		play.PP = EstimatePP(beatmap, score)
+	}
+	return play
+}
+
+func truncateScores(scores []ReportScore, n int) []ReportScore {
+	if len(scores) > n {
+		scores = scores[:n]
+	}
+	return append([]ReportScore(nil), scores...)
+}
+
+// Write the report as indented JSON
+func (this *PlayerReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(this)
+}
+
+// Write the report as a Markdown document
+func (this *PlayerReport) WriteMarkdown(w io.Writer) error {
+	var b strings.Builder
+	name := this.PlayerName
+	if name == "" {
+		name = "All players"
+	}
+	fmt.Fprintf(&b, "# %s\n\n", name)
+	fmt.Fprintf(&b, "- Total plays: %d\n", this.TotalPlays)
+	fmt.Fprintf(&b, "- Average accuracy: %.2f%%\n", this.AverageAccuracy*100)
+	for _, mode := range []GameMode{ModeStandard, ModeTaiko, ModeCatch, ModeMania} {
+		if count := this.PlaysPerMode[mode.String()]; count > 0 {
+			fmt.Fprintf(&b, "- %s plays: %d\n", mode, count)
+		}
+	}
+
+	b.WriteString("\n## Grades\n\n| Grade | Count |\n| --- | ---: |\n")
+	for grade := GradeSilverSS; grade < GradeNone; grade++ {
+		if count := this.GradeCounts[grade.String()]; count > 0 {
+			fmt.Fprintf(&b, "| %s | %d |\n", grade.DisplayName(), count)
+		}
+	}
+
+	b.WriteString("\n## Best scores\n\n")
+	writeMarkdownScores(&b, this.BestScores)
+	b.WriteString("\n## Top performance\n\n")
+	writeMarkdownScores(&b, this.TopPerformance)
+
+	b.WriteString("\n## Most played\n\n| Beatmap | Plays |\n| --- | ---: |\n")
+	for _, m := range this.MostPlayed {
+		fmt.Fprintf(&b, "| %s | %d |\n", markdownBeatmap(m.Beatmap, m.BeatmapMd5), m.Plays)
+	}
+
+	b.WriteString("\n## Plays over time\n\n| Month | Plays |\n| --- | ---: |\n")
+	for _, m := range this.PlaysOverTime {
+		fmt.Fprintf(&b, "| %s | %d |\n", m.Month, m.Plays)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeMarkdownScores(b *strings.Builder, scores []ReportScore) {
+	b.WriteString("| Beatmap | Player | Mode | Mods | Score | Accuracy | Grade | pp | Date |\n")
+	b.WriteString("| --- | --- | --- | --- | ---: | ---: | --- | ---: | --- |\n")
+	for _, s := range scores {
+		fmt.Fprintf(b, "| %s | %s | %s | %s | %d | %.2f%% | %s | %.0f | %s |\n",
+			markdownBeatmap(s.Beatmap, s.BeatmapMd5), markdownEscape(s.PlayerName),
+			s.Mode, s.Mods, s.Score, s.Accuracy*100, s.Grade, s.PP,
+			s.PlayedAt.Format("2006-01-02"))
+	}
+}
+
+func markdownBeatmap(name, md5 string) string {
+	if name == "" {
+		return "`" + md5 + "`"
+	}
+	return markdownEscape(name)
+}
+
+func markdownEscape(text string) string {
+	return strings.NewReplacer("|", "\\|", "[", "\\[", "]", "\\]").Replace(text)
+}
@@ -0,0 +1,88 @@
+package gosu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewPlayerReport(t *testing.T) {
+	score := func(player string, points, n300, nMiss int, mods Mods, played time.Time) ScoresDbBeatMapScore {
+		return ScoresDbBeatMapScore{
+			Md5Hash:                      NewString("aaaa"),
+			PlayerName:                   NewString(player),
+			Num300:                       Short(n300),
+			NumMiss:                      Short(nMiss),
+			ReplayScore:                  Int(points),
+			Mods:                         Int(mods),
+			TimestampOfReplayWindowTicks: Long(TimeToTicks(played)),
+		}
+	}
+	jan := time.Date(2018, 1, 10, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2018, 2, 10, 0, 0, 0, 0, time.UTC)
+	scores := &ScoresDb{
+		NumBeatmaps: 2,
+		Beatmaps: []ScoresDbBeatMap{
+			{Md5Hash: NewString("aaaa"), NumScores: 3, Scores: []ScoresDbBeatMapScore{
+				score("alice", 1000, 100, 0, ModHidden, jan),
+				score("alice", 2000, 90, 10, ModDoubleTime, feb),
+				score("bob", 5000, 100, 0, 0, feb),
+			}},
+			{Md5Hash: NewString("bbbb"), NumScores: 1, Scores: []ScoresDbBeatMapScore{
+				score("Alice", 500, 50, 50, 0, feb),
+			}},
+		},
+	}
+	scores.Beatmaps[1].Scores[0].Md5Hash = NewString("bbbb")
+
+	osu := NewOsuDb(Int(20171227), []BeatMap{{
+		Md5:                   NewString("aaaa"),
+		ArtistName:            NewString("gosu"),
+		SongTitle:             NewString("Test Song"),
+		Difficulty:            NewString("Normal"),
+		NumHitCircles:         100,
+		OverallDifficulty:     6,
+		OsuStandardStarRating: []IntDoublePair{{IntValue: 0, DoubleValue: 4}, {IntValue: Int(ModDoubleTime), DoubleValue: 5.5}},
+	}})
+
+	report := NewPlayerReport(scores, PlayerReportOptions{PlayerName: "alice", OsuDb: osu})
+	if report.TotalPlays != 3 {
+		t.Errorf("Expected 3 plays, got %d", report.TotalPlays)
+	}
+	if report.PlaysPerMode["osu!"] != 3 {
+		t.Errorf("Expected 3 osu! plays, got %v", report.PlaysPerMode)
+	}
+	if report.GradeCounts["XH"] != 1 || report.GradeCounts["B"] != 1 || report.GradeCounts["D"] != 1 {
+		t.Errorf("Unexpected grade counts %v", report.GradeCounts)
+	}
+	if len(report.BestScores) != 2 || report.BestScores[0].Score != 2000 ||
+		report.BestScores[0].Beatmap != "gosu - Test Song [Normal]" {
+		t.Errorf("Unexpected best scores %+v", report.BestScores)
+	}
+	if len(report.MostPlayed) != 2 || report.MostPlayed[0].Plays != 2 {
+		t.Errorf("Unexpected most played %+v", report.MostPlayed)
+	}
+	if len(report.PlaysOverTime) != 2 || report.PlaysOverTime[0] != (MonthPlayCount{"2018-01", 1}) ||
+		report.PlaysOverTime[1] != (MonthPlayCount{"2018-02", 2}) {
+		t.Errorf("Unexpected plays over time %+v", report.PlaysOverTime)
+	}
+	top := report.TopPerformance
+	if len(top) != 3 || top[0].Mods != "DT" || top[0].PP <= top[1].PP || top[2].PP != 0 {
+		t.Errorf("Unexpected top performance %+v", top)
+	}
+
+	var markdown, json bytes.Buffer
+	if err := report.WriteMarkdown(&markdown); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(markdown.String(), "| Silver SS | 1 |") {
+		t.Errorf("Markdown is missing the grades:\n%s", markdown.String())
+	}
+	if err := report.WriteJSON(&json); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(json.String(), `"total_plays": 3`) {
+		t.Errorf("JSON is missing the total plays:\n%s", json.String())
+	}
+}
@@ -0,0 +1,111 @@
+package gosu
+
+import (
+	"math"
+)
+
+// An estimate of the performance points (pp) of a score. osu!.db only stores
+// the total star rating of a beatmap, not its aim/speed split or max combo,
+// so this is not the exact value the website shows. It is close enough to
+// rank the scores of one player against each other.
+
+// The mods which change the star rating stored in osu!.db
+const starRatingMods = ModEasy | ModHardRock | ModDoubleTime | ModHalfTime
+
+// Returns the star rating the client stored for the mode and mods, or 0 if
+// the client has not computed it yet.
+func (this *BeatMap) StarRating(mode GameMode, mods Mods) float64 {
+	var ratings []IntDoublePair
+	switch mode {
+	case ModeStandard:
+		ratings = this.OsuStandardStarRating
+	case ModeTaiko:
+		ratings = this.TaikoStarRating
+	case ModeCatch:
+		ratings = this.CTBStarRating
+	case ModeMania:
+		ratings = this.ManiaStarRating
+	}
+	if mods.Has(ModNightcore) {
+		mods |= ModDoubleTime
+	}
+	mods &= starRatingMods
+	for _, rating := range ratings {
+		if Mods(rating.IntValue) == mods {
+			return float64(rating.DoubleValue)
+		}
+	}
+	return 0
+}
+
+// Estimate the pp of the score on the beatmap
+func EstimatePP(beatmap *BeatMap, score *ScoresDbBeatMapScore) float64 {
+	mode := GameMode(score.GameplayMode)
+	mods := Mods(score.Mods)
+	stars := beatmap.StarRating(mode, mods)
+	if stars <= 0 {
+		return 0
+	}
+	counts := score.HitCounts()
+	accuracy := Accuracy(mode, counts)
+	numObjects := counts.Count300 + counts.Count100 + counts.Count50 + counts.CountMiss
+	lengthBonus := 0.95 + 0.4*math.Min(1, float64(numObjects)/2000)
+	if numObjects > 2000 {
+		lengthBonus += math.Log10(float64(numObjects)/2000) * 0.5
+	}
+	missPenalty := math.Pow(0.97, float64(counts.CountMiss))
+
+	multiplier := 1.12
+	if mods.Has(ModNoFail) {
+		multiplier *= 0.9
+	}
+	if mods.Has(ModSpunOut) {
+		multiplier *= 0.95
+	}
+
+	if mode != ModeStandard {
+		return strainValue(stars) * lengthBonus * missPenalty *
+			math.Pow(accuracy, 4) * multiplier
+	}
+
+	// Assume aim and speed contribute equally to the star rating
+	skill := strainValue(stars / 2)
+	od := applyDifficultyMods(float64(beatmap.OverallDifficulty), mods)
+	od = (80 - (80-6*od)/mods.ClockRate()) / 6
+	odBonus := 0.98 + od*od/2500
+
+	aim := skill * lengthBonus * missPenalty * (0.5 + accuracy/2) * odBonus
+	speed := skill * lengthBonus * missPenalty * (0.02 + accuracy) * odBonus
+	if mods.Has(ModHidden) {
+		aim *= 1.18
+		speed *= 1.18
+	}
+	if mods.Has(ModFlashlight) {
+		aim *= 1.45 * lengthBonus
+	}
+
+	circles := int(beatmap.NumHitCircles)
+	betterAccuracy := 0.0
+	if circles > 0 {
+		numNonCircles := numObjects - circles
+		betterAccuracy = float64((counts.Count300-numNonCircles)*6+counts.Count100*2+counts.Count50) /
+			float64(circles*6)
+		betterAccuracy = math.Max(betterAccuracy, 0)
+	}
+	acc := math.Pow(1.52163, od) * math.Pow(betterAccuracy, 24) * 2.83 *
+		math.Min(1.15, math.Pow(float64(circles)/1000, 0.3))
+	if mods.Has(ModHidden) {
+		acc *= 1.02
+	}
+	if mods.Has(ModFlashlight) {
+		acc *= 1.02
+	}
+
+	return math.Pow(math.Pow(aim, 1.1)+math.Pow(speed, 1.1)+math.Pow(acc, 1.1), 1/1.1) *
+		multiplier
+}
+
+// Converts a (partial) star rating into pp
+func strainValue(stars float64) float64 {
+	return math.Pow(5*math.Max(1, stars/0.0675)-4, 3) / 100000
+}
@@ -0,0 +1,169 @@
+package gosu
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Helpers for making sense of the PlayerPresence records in presence.db.
+
+// The country codes used by the osu! client, indexed by the Country byte.
+// Index 0 is used for players whose country is unknown.
+var countryCodes = [...]string{
+	"XX", "AP", "EU", "AD", "AE", "AF", "AG", "AI", "AL", "AM", "AN", "AO",
+	"AQ", "AR", "AS", "AT", "AU", "AW", "AZ", "BA", "BB", "BD", "BE", "BF",
+	"BG", "BH", "BI", "BJ", "BM", "BN", "BO", "BR", "BS", "BT", "BV", "BW",
+	"BY", "BZ", "CA", "CC", "CD", "CF", "CG", "CH", "CI", "CK", "CL", "CM",
+	"CN", "CO", "CR", "CU", "CV", "CX", "CY", "CZ", "DE", "DJ", "DK", "DM",
+	"DO", "DZ", "EC", "EE", "EG", "EH", "ER", "ES", "ET", "FI", "FJ", "FK",
+	"FM", "FO", "FR", "FX", "GA", "GB", "GD", "GE", "GF", "GH", "GI", "GL",
+	"GM", "GN", "GP", "GQ", "GR", "GS", "GT", "GU", "GW", "GY", "HK", "HM",
+	"HN", "HR", "HT", "HU", "ID", "IE", "IL", "IN", "IO", "IQ", "IR", "IS",
+	"IT", "JM", "JO", "JP", "KE", "KG", "KH", "KI", "KM", "KN", "KP", "KR",
+	"KW", "KY", "KZ", "LA", "LB", "LC", "LI", "LK", "LR", "LS", "LT", "LU",
+	"LV", "LY", "MA", "MC", "MD", "MG", "MH", "MK", "ML", "MM", "MN", "MO",
+	"MP", "MQ", "MR", "MS", "MT", "MU", "MV", "MW", "MX", "MY", "MZ", "NA",
+	"NC", "NE", "NF", "NG", "NI", "NL", "NO", "NP", "NR", "NU", "NZ", "OM",
+	"PA", "PE", "PF", "PG", "PH", "PK", "PL", "PM", "PN", "PR", "PS", "PT",
+	"PW", "PY", "QA", "RE", "RO", "RU", "RW", "SA", "SB", "SC", "SD", "SE",
+	"SG", "SH", "SI", "SJ", "SK", "SL", "SM", "SN", "SO", "SR", "ST", "SV",
+	"SY", "SZ", "TC", "TD", "TF", "TG", "TH", "TJ", "TK", "TM", "TN", "TO",
+	"TL", "TR", "TT", "TV", "TW", "TZ", "UA", "UG", "UM", "US", "UY", "UZ",
+	"VA", "VC", "VE", "VG", "VI", "VN", "VU", "WF", "WS", "YE", "YT", "RS",
+	"ZA", "ZM", "ME", "ZW", "A1", "A2", "O1", "AX", "GG", "IM", "JE", "BL",
+	"MF",
+}
+
+// Returns the ISO 3166-1 alpha-2 code for the osu! country byte, or "XX" if
+// the country is unknown.
+func CountryCode(country Byte) string {
+	if int(country) >= len(countryCodes) {
+		return "XX"
+	}
+	return countryCodes[country]
+}
+
+// Returns the osu! country byte for the ISO 3166-1 alpha-2 code
+func CountryByte(code string) (Byte, bool) {
+	code = strings.ToUpper(code)
+	for i, c := range countryCodes {
+		if c == code {
+			return Byte(i), true
+		}
+	}
+	return 0, false
+}
+
+// Returns the ISO country code of the player
+func (this *PlayerPresence) CountryCode() string {
+	return CountryCode(this.Country)
+}
+
+// Returns the player's offset from UTC. The client stores the offset in
+// hours plus 24 so that it fits in an unsigned byte.
+func (this *PlayerPresence) UtcOffsetDuration() time.Duration {
+	return time.Duration(int(this.UtcOffset)-24) * time.Hour
+}
+
+// Returns a fixed time zone for the player's UTC offset
+func (this *PlayerPresence) Location() *time.Location {
+	hours := int(this.UtcOffset) - 24
+	return time.FixedZone(fmt.Sprintf("UTC%+d", hours), hours*60*60)
+}
+
+// Find the player with the given name, ignoring case. Returns nil if there
+// is no such player.
+func (this *PresenceDb) FindPlayerByName(name string) *PlayerPresence {
+	for i := range this.Players {
+		if strings.EqualFold(this.Players[i].PlayerName.Text, name) {
+			return &this.Players[i]
+		}
+	}
+	return nil
+}
+
+// Find the player with the given ID. Returns nil if there is no such player.
+func (this *PresenceDb) FindPlayerByID(id Int) *PlayerPresence {
+	for i := range this.Players {
+		if this.Players[i].PlayerId == id {
+			return &this.Players[i]
+		}
+	}
+	return nil
+}
+
+// Returns the number of players in each country, keyed by ISO country code
+func (this *PresenceDb) PlayersPerCountry() map[string]int {
+	counts := make(map[string]int)
+	for i := range this.Players {
+		counts[this.Players[i].CountryCode()]++
+	}
+	return counts
+}
+
+// Returns the number of ranked players in each bucket of global ranks.
+// Bucket i counts the players ranked [i*bucketSize+1, (i+1)*bucketSize].
+// Players without a rank (rank 0) are not counted.
+func (this *PresenceDb) RankHistogram(bucketSize int) []int {
+	var histogram []int
+	if bucketSize <= 0 {
+		return histogram
+	}
+	for i := range this.Players {
+		rank := int(this.Players[i].GlobalRank)
+		if rank == 0 {
+			continue
+		}
+		bucket := (rank - 1) / bucketSize
+		for len(histogram) <= bucket {
+			histogram = append(histogram, 0)
+		}
+		histogram[bucket]++
+	}
+	return histogram
+}
+
+// A player and their distance from a point on the globe
+type PlayerDistance struct {
+	Player     *PlayerPresence
+	DistanceKm float64
+}
+
+// Returns up to n players closest to the given coordinates, nearest first.
+// Players without a location (both coordinates 0) are skipped. Returns
+// nothing if n is 0 or less.
+func (this *PresenceDb) NearestPlayers(latitude, longitude float64, n int) []PlayerDistance {
+	if n <= 0 {
+		return nil
+	}
+	var players []PlayerDistance
+	for i := range this.Players {
+		player := &this.Players[i]
+		if player.Latitude == 0 && player.Longitude == 0 {
+			continue
+		}
+		players = append(players, PlayerDistance{player, haversineKm(
+			latitude, longitude, float64(player.Latitude), float64(player.Longitude))})
+	}
+	sort.SliceStable(players, func(i, j int) bool {
+		return players[i].DistanceKm < players[j].DistanceKm
+	})
+	if len(players) > n {
+		players = players[:n]
+	}
+	return players
+}
+
+// Returns the great-circle distance between two coordinates in km
+func haversineKm(lat1, long1, lat2, long2 float64) float64 {
+	const earthRadiusKm = 6371
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(lat2 - lat1)
+	dLong := toRad(long2 - long1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLong/2)*math.Sin(dLong/2)
+	return 2 * earthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
@@ -0,0 +1,61 @@
+package gosu
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPresenceDbHelpers(t *testing.T) {
+	file, err := os.Open("data/presence.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var db PresenceDb
+	if err := db.UnmarshalOsuBinary(file, Int(20171227)); err != nil {
+		t.Fatal(err)
+	}
+
+	player := db.FindPlayerByName("Stymphalian")
+	if player == nil {
+		t.Fatal("Expected to find stymphalian")
+	}
+	if db.FindPlayerByID(player.PlayerId) != player {
+		t.Errorf("FindPlayerByID did not return the same player")
+	}
+	if player.CountryCode() != "US" {
+		t.Errorf("Got country %s, want US", player.CountryCode())
+	}
+	if player.UtcOffsetDuration() != -8*time.Hour {
+		t.Errorf("Got UTC offset %v, want -8h", player.UtcOffsetDuration())
+	}
+	if code, ok := CountryByte("us"); !ok || code != player.Country {
+		t.Errorf("CountryByte(us) = %d, want %d", code, player.Country)
+	}
+
+	total := 0
+	for _, count := range db.PlayersPerCountry() {
+		total += count
+	}
+	if total != len(db.Players) {
+		t.Errorf("PlayersPerCountry counted %d of %d players", total, len(db.Players))
+	}
+
+	nearest := db.NearestPlayers(float64(player.Latitude), float64(player.Longitude), 3)
+	if len(nearest) != 3 || nearest[0].DistanceKm != 0 {
+		t.Errorf("Expected stymphalian to be nearest to themself, got %v", nearest)
+	}
+	if nearest := db.NearestPlayers(0, 0, -1); len(nearest) != 0 {
+		t.Errorf("Expected no players for n = -1, got %v", nearest)
+	}
+
+	ranked := 0
+	for _, count := range db.RankHistogram(100000) {
+		ranked += count
+	}
+	if ranked == 0 || ranked > len(db.Players) {
+		t.Errorf("RankHistogram counted %d ranked players", ranked)
+	}
+}
@@ -0,0 +1,269 @@
+package gosu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// The .osr replay file format.
+// See https://github.com/ppy/osu-wiki/blob/master/wiki/osu!_File_Formats/Osr_(file_format)/en.md
+// for the spec of these fields. The header shares its layout with
+// ScoresDbBeatMapScore, so the hit count fields use the same names.
+//
+//gosu:codec
+type Replay struct {
+	GameplayMode   Byte `osu-check:"mode"`
+	Version        Int
+	BeatmapMd5Hash String `osu-check:"md5"`
+	PlayerName     String
+	ReplayMd5Hash  String `osu-check:"md5"`
+	Num300         Short
+	Num200         Short
+	Num50          Short
+	NumMax300      Short
+	Num100         Short
+	NumMiss        Short
+	ReplayScore    Int
+	MaxCombo       Short
+	IsPerfectCombo Boolean
+	Mods           Int
+	// Comma separated "msec|hp" pairs of the life bar graph
+	LifeBarGraph String
+	// .NET ticks of when the replay was played
+	TimestampTicks Long
+	// LZMA compressed frame data, see Frames()
+	NumReplayData Int
+	ReplayData    []Byte
+	OnlineScoreId Long `osu-start:"20140721"`
+}
+
+// The number of each judgement, independent of the game mode. The .osr and
+// scores.db formats store these in six shorts whose meaning depends on the
+// mode; HitCounts() maps them onto these names.
+type HitCounts struct {
+	Count300  int
+	Count100  int
+	Count50   int
+	CountGeki int
+	CountKatu int
+	CountMiss int
+}
+
+// Returns the hit counts of the score. The second short (Num200) holds the
+// number of 100s, and Num100 the number of katus.
+func (this *ScoresDbBeatMapScore) HitCounts() HitCounts {
+	return HitCounts{
+		Count300:  int(this.Num300),
+		Count100:  int(this.Num200),
+		Count50:   int(this.Num50),
+		CountGeki: int(this.NumMax300),
+		CountKatu: int(this.Num100),
+		CountMiss: int(this.NumMiss),
+	}
+}
+
+// Returns the hit counts of the replay, see ScoresDbBeatMapScore.HitCounts()
+func (this *Replay) HitCounts() HitCounts {
+	return HitCounts{
+		Count300:  int(this.Num300),
+		Count100:  int(this.Num200),
+		Count50:   int(this.Num50),
+		CountGeki: int(this.NumMax300),
+		CountKatu: int(this.Num100),
+		CountMiss: int(this.NumMiss),
+	}
+}
+
+// Bit flags of ReplayFrame.Keys
+const (
+	KeyMouse1 = 1 << 0
+	KeyMouse2 = 1 << 1
+	KeyK1     = 1 << 2
+	KeyK2     = 1 << 3
+	KeySmoke  = 1 << 4
+)
+
+// The frame used by newer clients to store the seed of the random number
+// generator. It is not a cursor movement.
+const replaySeedFrameDelta = -12345
+
+// A single cursor/key state of a replay
+type ReplayFrame struct {
+	// Msec since the previous frame
+	TimeDeltaMsec int64
+	// Msec since the start of the song
+	TimeMsec int64
+	X        float64
+	Y        float64
+	// Bitwise combination of the Key* constants. For osu!mania this holds the
+	// pressed columns instead.
+	Keys int
+}
+
+// Returns true if either of the osu!standard buttons is held
+func (this *ReplayFrame) Pressed() bool {
+	return this.Keys&(KeyMouse1|KeyMouse2) != 0
+}
+
+// Read and decode the .osr file at the given path
+func ReadReplayFile(path string) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	version, err := GetVersionOfReplay(file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	replay := &Replay{}
+	if err := replay.UnmarshalOsuBinary(file, version); err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+// The position of NumReplayData, the last field of the replay's header
+var replayNumDataField = fieldIndex(Replay{}, "NumReplayData")
+
+// Read only the header of the .osr file at the given path, up to and
+// including NumReplayData. The compressed frame data is not decoded, so
+// ReplayData is left empty and OnlineScoreId zero.
+func ReadReplayHeader(path string) (*Replay, error) {
+	mapped, err := MapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer mapped.Close()
+
+	replay := &Replay{}
+	version, err := versionOfData(replay, mapped.Data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := NewDecoder(mapped.Data, version)
+	if err := decoder.decodeFields(replay, 0, replayNumDataField+1); err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+// Returns the version of the client which wrote the replay. Unlike the DB
+// files the version of a replay follows the game mode byte.
+func GetVersionOfReplay(buf io.Reader) (Int, error) {
+	var mode Byte
+	if err := mode.UnmarshalOsuBinary(buf, Int(0)); err != nil {
+		return Int(0), err
+	}
+	return GetVersionOfBinary(buf)
+}
+
+// Decompress and parse the cursor/key frames of the replay.
+// The seed frame written by newer clients is skipped.
+func (this *Replay) Frames() ([]ReplayFrame, error) {
+	if len(this.ReplayData) == 0 {
+		return nil, nil
+	}
+	// A []Byte shares the layout of a []byte, reflect converts it without a copy
+	compressed := reflect.ValueOf(this.ReplayData).Bytes()
+	reader, err := lzma.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return ParseReplayFrames(string(data))
+}
+
+// Encode and LZMA compress the frames into the ReplayData of the replay.
+// The TimeMsec of the frames is used to recompute their TimeDeltaMsec. A
+// seed frame is appended like the client does.
+func (this *Replay) SetFrames(frames []ReplayFrame) error {
+	var data bytes.Buffer
+	var previous int64
+	for i, frame := range frames {
+		delta := frame.TimeMsec - previous
+		if i == 0 {
+			delta = frame.TimeMsec
+		}
+		previous = frame.TimeMsec
+		fmt.Fprintf(&data, "%d|%s|%s|%d,", delta,
+			strconv.FormatFloat(frame.X, 'f', -1, 32),
+			strconv.FormatFloat(frame.Y, 'f', -1, 32), frame.Keys)
+	}
+	fmt.Fprintf(&data, "%d|0|0|0,", replaySeedFrameDelta)
+
+	var compressed bytes.Buffer
+	writer, err := lzma.WriterConfig{
+		SizeInHeader: true,
+		Size:         int64(data.Len()),
+	}.NewWriter(&compressed)
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write(data.Bytes()); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	reflect.ValueOf(&this.ReplayData).Elem().SetBytes(compressed.Bytes())
+	this.NumReplayData = Int(len(this.ReplayData))
+	return nil
+}
+
+// Parse the decompressed "w|x|y|z," frame data of a replay
+func ParseReplayFrames(data string) ([]ReplayFrame, error) {
+	var frames []ReplayFrame
+	var timeMsec int64
+	for _, entry := range strings.Split(data, ",") {
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, "|")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("Invalid replay frame %q", entry)
+		}
+		delta, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if delta == replaySeedFrameDelta {
+			continue
+		}
+		x, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, err
+		}
+		y, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return nil, err
+		}
+		keys, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return nil, err
+		}
+		timeMsec += delta
+		frames = append(frames, ReplayFrame{delta, timeMsec, x, y, keys})
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("Replay does not contain any frames")
+	}
+	return frames, nil
+}
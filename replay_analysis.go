@@ -0,0 +1,380 @@
+package gosu
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Matches the frames of an osu!standard replay against the hit objects of the
+// parsed beatmap to reconstruct how every object was hit.
+//
+// This is an approximation of the client's judgement: circles and slider
+// heads are judged on the first unused key press within the 50 hit window
+// whose cursor is on the circle, sliders are judged only by whether a key is
+// held until their end (follow circle tracking is not simulated), spinners
+// only need a key to be held throughout, and stacking is ignored. The hit
+// errors of circles and slider heads, and therefore the unstable rate, match
+// the client's whenever the judgements do.
+
+// The size of the playfield in osu!pixels
+const (
+	PlayfieldWidth  = 512
+	PlayfieldHeight = 384
+)
+
+type HitResult int
+
+const (
+	HitMiss HitResult = iota
+	Hit50
+	Hit100
+	Hit300
+)
+
+func (this HitResult) String() string {
+	switch this {
+	case Hit300:
+		return "300"
+	case Hit100:
+		return "100"
+	case Hit50:
+		return "50"
+	}
+	return "miss"
+}
+
+// The judgement of a single hit object
+type ObjectHit struct {
+	// Index into OsuFile.HitObjects
+	Index  int
+	Result HitResult
+	// Msec the object was hit early (negative) or late (positive). Only
+	// meaningful for circles and slider heads which were hit.
+	ErrorMsec float64
+	// Whether ErrorMsec is set
+	HasError bool
+	// Set for sliders whose head was hit but whose key was released early
+	SliderBreak bool
+}
+
+// Counts the replay frames in each CellSize x CellSize square of the
+// playfield. Positions outside the playfield are clamped to its border.
+type CursorHeatmap struct {
+	CellSize int
+	// Cells[row][column]
+	Cells [][]int
+}
+
+type ReplayAnalysis struct {
+	Hits   []ObjectHit
+	Counts HitCounts
+
+	// 10 times the standard deviation of the hit errors
+	UnstableRate float64
+	// The mean of all hit errors
+	MeanErrorMsec float64
+	// The mean of the early (negative) and late (positive) hit errors, as
+	// shown on the client's results screen.
+	MeanEarlyMsec float64
+	MeanLateMsec  float64
+
+	SliderBreaks int
+	Heatmap      CursorHeatmap
+}
+
+// The default cell size of the cursor heatmap in osu!pixels
+const DefaultHeatmapCellSize = 16
+
+// Decode the replay's frames and analyse them against the beatmap.
+// Only osu!standard replays are supported.
+func AnalyzeReplay(osu *OsuFile, replay *Replay) (*ReplayAnalysis, error) {
+	if GameMode(replay.GameplayMode) != ModeStandard || osu.Mode != ModeStandard {
+		return nil, errors.New("Only osu!standard replays can be analysed")
+	}
+	frames, err := replay.Frames()
+	if err != nil {
+		return nil, err
+	}
+	return AnalyzeFrames(osu, frames, Mods(replay.Mods)), nil
+}
+
+// Analyse the replay frames against the beatmap played with the given mods.
+func AnalyzeFrames(osu *OsuFile, frames []ReplayFrame, mods Mods) *ReplayAnalysis {
+	analysis := &ReplayAnalysis{}
+	windows := newHitWindows(osu.OverallDifficulty, mods)
+	radius := circleRadiusWithMods(osu.CircleSize, mods)
+	presses := keyPresses(frames)
+
+	used := make([]bool, len(presses))
+	next := 0
+	for i := range osu.HitObjects {
+		h := &osu.HitObjects[i]
+		x, y := float64(h.X), float64(h.Y)
+		if mods.Has(ModHardRock) {
+			y = PlayfieldHeight - y
+		}
+		hit := ObjectHit{Index: i}
+
+		if h.IsSpinner() {
+			if heldDuring(frames, float64(h.TimeMsec), float64(h.EndTimeMsec)) {
+				hit.Result = Hit300
+			}
+			analysis.Hits = append(analysis.Hits, hit)
+			continue
+		}
+
+		start := float64(h.TimeMsec)
+		// Skip the presses which are too early for this object
+		for next < len(presses) && presses[next].TimeMsec < int64(start-windows.Hit50) {
+			next++
+		}
+		for j := next; j < len(presses); j++ {
+			press := presses[j]
+			if float64(press.TimeMsec) > start+windows.Hit50 {
+				break
+			}
+			if used[j] || math.Hypot(press.X-x, press.Y-y) > radius {
+				continue
+			}
+			used[j] = true
+			hit.ErrorMsec = float64(press.TimeMsec) - start
+			hit.HasError = true
+			hit.Result = windows.judge(hit.ErrorMsec)
+			break
+		}
+
+		if h.IsSlider() && hit.HasError {
+			end := float64(osu.EndTimeOf(h))
+			// The client is lenient about releasing just before the end
+			const sliderEndLeniencyMsec = 36
+			if !heldDuring(frames, start+hit.ErrorMsec, end-sliderEndLeniencyMsec) {
+				hit.SliderBreak = true
+				hit.Result = Hit100
+			} else {
+				hit.Result = Hit300
+			}
+		}
+		analysis.Hits = append(analysis.Hits, hit)
+	}
+
+	analysis.countHits(osu)
+	analysis.computeErrorStats()
+	analysis.Heatmap = NewCursorHeatmap(frames, DefaultHeatmapCellSize)
+	return analysis
+}
+
+// Returns the differences between the analysed hit counts and the counts
+// recorded for the score, e.g. from ScoresDbBeatMapScore.HitCounts().
+// An empty result means the analysis agrees with the client.
+func (this *ReplayAnalysis) CompareCounts(recorded HitCounts) []string {
+	var mismatches []string
+	compare := func(name string, got, want int) {
+		if got != want {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"%s: analysed %d, recorded %d", name, got, want))
+		}
+	}
+	compare("300", this.Counts.Count300, recorded.Count300)
+	compare("100", this.Counts.Count100, recorded.Count100)
+	compare("50", this.Counts.Count50, recorded.Count50)
+	compare("geki", this.Counts.CountGeki, recorded.CountGeki)
+	compare("katu", this.Counts.CountKatu, recorded.CountKatu)
+	compare("miss", this.Counts.CountMiss, recorded.CountMiss)
+	return mismatches
+}
+
+func (this *ReplayAnalysis) countHits(osu *OsuFile) {
+	// Gekis and katus are awarded at the end of each combo: a geki if every
+	// object was a 300, a katu if there were 100s but no 50s or misses.
+	comboStart := 0
+	endCombo := func(end int) {
+		if end <= comboStart {
+			return
+		}
+		has100, has50OrMiss := false, false
+		for _, hit := range this.Hits[comboStart:end] {
+			switch hit.Result {
+			case Hit100:
+				has100 = true
+			case Hit50, HitMiss:
+				has50OrMiss = true
+			}
+		}
+		switch {
+		case !has100 && !has50OrMiss:
+			this.Counts.CountGeki++
+		case has100 && !has50OrMiss:
+			this.Counts.CountKatu++
+		}
+		comboStart = end
+	}
+
+	for i, hit := range this.Hits {
+		if i > 0 && osu.HitObjects[hit.Index].Type&HitObjectNewCombo != 0 {
+			endCombo(i)
+		}
+		switch hit.Result {
+		case Hit300:
+			this.Counts.Count300++
+		case Hit100:
+			this.Counts.Count100++
+		case Hit50:
+			this.Counts.Count50++
+		default:
+			this.Counts.CountMiss++
+		}
+		if hit.SliderBreak {
+			this.SliderBreaks++
+		}
+	}
+	endCombo(len(this.Hits))
+}
+
+func (this *ReplayAnalysis) computeErrorStats() {
+	var hitErrors []float64
+	early, late := 0.0, 0.0
+	numEarly, numLate := 0, 0
+	for _, hit := range this.Hits {
+		if !hit.HasError {
+			continue
+		}
+		hitErrors = append(hitErrors, hit.ErrorMsec)
+		if hit.ErrorMsec < 0 {
+			early += hit.ErrorMsec
+			numEarly++
+		} else {
+			late += hit.ErrorMsec
+			numLate++
+		}
+	}
+	if len(hitErrors) == 0 {
+		return
+	}
+	if numEarly > 0 {
+		this.MeanEarlyMsec = early / float64(numEarly)
+	}
+	if numLate > 0 {
+		this.MeanLateMsec = late / float64(numLate)
+	}
+
+	sum := 0.0
+	for _, e := range hitErrors {
+		sum += e
+	}
+	this.MeanErrorMsec = sum / float64(len(hitErrors))
+	variance := 0.0
+	for _, e := range hitErrors {
+		variance += (e - this.MeanErrorMsec) * (e - this.MeanErrorMsec)
+	}
+	this.UnstableRate = math.Sqrt(variance/float64(len(hitErrors))) * 10
+}
+
+// Build the heatmap of the cursor positions of the frames
+func NewCursorHeatmap(frames []ReplayFrame, cellSize int) CursorHeatmap {
+	heatmap := CursorHeatmap{CellSize: cellSize}
+	if cellSize <= 0 {
+		return heatmap
+	}
+	rows := (PlayfieldHeight + cellSize - 1) / cellSize
+	columns := (PlayfieldWidth + cellSize - 1) / cellSize
+	heatmap.Cells = make([][]int, rows)
+	for i := range heatmap.Cells {
+		heatmap.Cells[i] = make([]int, columns)
+	}
+	clamp := func(v float64, n int) int {
+		cell := int(v) / cellSize
+		if v < 0 || cell < 0 {
+			return 0
+		}
+		if cell >= n {
+			return n - 1
+		}
+		return cell
+	}
+	for _, frame := range frames {
+		heatmap.Cells[clamp(frame.Y, rows)][clamp(frame.X, columns)]++
+	}
+	return heatmap
+}
+
+// The hit windows (in msec either side of the object) of each judgement
+type hitWindows struct {
+	Hit300 float64
+	Hit100 float64
+	Hit50  float64
+}
+
+func newHitWindows(od float64, mods Mods) hitWindows {
+	od = applyDifficultyMods(od, mods)
+	return hitWindows{80 - 6*od, 140 - 8*od, 200 - 10*od}
+}
+
+func (this hitWindows) judge(errorMsec float64) HitResult {
+	e := math.Abs(errorMsec)
+	switch {
+	case e <= this.Hit300:
+		return Hit300
+	case e <= this.Hit100:
+		return Hit100
+	case e <= this.Hit50:
+		return Hit50
+	}
+	return HitMiss
+}
+
+func circleRadiusWithMods(cs float64, mods Mods) float64 {
+	if mods.Has(ModHardRock) {
+		cs = math.Min(cs*1.3, 10)
+	} else if mods.Has(ModEasy) {
+		cs *= 0.5
+	}
+	return 54.4 - 4.48*cs
+}
+
+// Apply HardRock/Easy to an OD/HP/AR value
+func applyDifficultyMods(value float64, mods Mods) float64 {
+	if mods.Has(ModHardRock) {
+		return math.Min(value*1.4, 10)
+	}
+	if mods.Has(ModEasy) {
+		return value * 0.5
+	}
+	return value
+}
+
+// Returns the frames at which a button which was up in the previous frame is
+// pressed.
+func keyPresses(frames []ReplayFrame) []ReplayFrame {
+	var presses []ReplayFrame
+	previous := 0
+	for _, frame := range frames {
+		keys := frame.Keys & (KeyMouse1 | KeyMouse2)
+		if keys&^previous != 0 {
+			presses = append(presses, frame)
+		}
+		previous = keys
+	}
+	return presses
+}
+
+// Returns true if a button is held for every frame within [from, to]
+func heldDuring(frames []ReplayFrame, from, to float64) bool {
+	held := false
+	for _, frame := range frames {
+		t := float64(frame.TimeMsec)
+		// The state at "from" is the state of the last frame before it
+		if t <= from {
+			held = frame.Pressed()
+			continue
+		}
+		if t > to {
+			break
+		}
+		if !frame.Pressed() {
+			return false
+		}
+		held = true
+	}
+	return held
+}
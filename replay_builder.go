@@ -0,0 +1,234 @@
+package gosu
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"time"
+)
+
+// Builds synthetic .osr replays, mainly for testing replay analysis tools.
+
+// Everything needed to build a Replay of a beatmap
+type ReplayBuilder struct {
+	// The parsed beatmap the replay is played on
+	Beatmap *OsuFile
+	// The hex encoded MD5 of the beatmap's .osu file
+	BeatmapMd5 string
+
+	PlayerName string
+	Mods       Mods
+	// The score shown for the replay. The builder does not simulate scoring.
+	Score     int
+	Timestamp time.Time
+	// The client version the replay claims to be written by
+	Version Int
+
+	// The cursor/key frames of the replay. When empty the frames are
+	// generated by AutoPlayFrames.
+	Frames []ReplayFrame
+}
+
+// Build the replay. The hit counts, max combo and replay hash are computed
+// from the frames by analysing them against the beatmap, so that they are
+// consistent with each other.
+func (this *ReplayBuilder) Build() (*Replay, error) {
+	if this.Beatmap == nil {
+		return nil, errors.New("ReplayBuilder needs a Beatmap")
+	}
+	if this.Beatmap.Mode != ModeStandard {
+		return nil, errors.New("Only osu!standard replays can be built")
+	}
+	frames := this.Frames
+	if len(frames) == 0 {
+		frames = AutoPlayFrames(this.Beatmap, this.Mods)
+	}
+
+	analysis := AnalyzeFrames(this.Beatmap, frames, this.Mods)
+	counts := analysis.Counts
+	maxCombo, perfect := analysis.maxCombo(this.Beatmap)
+
+	replay := &Replay{
+		GameplayMode:   Byte(ModeStandard),
+		Version:        this.Version,
+		BeatmapMd5Hash: NewString(this.BeatmapMd5),
+		PlayerName:     NewString(this.PlayerName),
+		Num300:         Short(counts.Count300),
+		Num200:         Short(counts.Count100),
+		Num50:          Short(counts.Count50),
+		NumMax300:      Short(counts.CountGeki),
+		Num100:         Short(counts.CountKatu),
+		NumMiss:        Short(counts.CountMiss),
+		ReplayScore:    Int(this.Score),
+		MaxCombo:       Short(maxCombo),
+		Mods:           Int(this.Mods),
+		LifeBarGraph:   NewString(""),
+		TimestampTicks: Long(TimeToTicks(this.Timestamp)),
+	}
+	if perfect {
+		replay.IsPerfectCombo = 1
+	}
+	replay.ReplayMd5Hash = NewString(replay.ComputeReplayMd5(replay.Grade().String()))
+	if err := replay.SetFrames(frames); err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+// Compute the replay hash the way the client does, from the hit counts,
+// beatmap, player, score and the grade name (e.g. "S" or "XH").
+func (this *Replay) ComputeReplayMd5(grade string) string {
+	counts := this.HitCounts()
+	perfect := "False"
+	if this.IsPerfectCombo != 0 {
+		perfect = "True"
+	}
+	text := fmt.Sprintf("%dp%do%do%dt%da%sr%de%sy%so%du%s%d%s",
+		counts.Count100+counts.Count300, counts.Count50, counts.CountGeki,
+		counts.CountKatu, counts.CountMiss, this.BeatmapMd5Hash.Text,
+		this.MaxCombo, perfect, this.PlayerName.Text, this.ReplayScore,
+		grade, this.Mods, "True")
+	hash := md5.Sum([]byte(text))
+	return hex.EncodeToString(hash[:])
+}
+
+// Write the replay to the .osr file at the given path
+func WriteReplayFile(path string, replay *Replay) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := replay.MarshalOsuBinary(file, replay.Version); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// The interval between the generated frames of spinners
+const autoPlayFrameMsec = 16
+
+// Generate frames which move the cursor onto every hit object, press on it
+// exactly on time and hold through sliders and spinners, alternating between
+// the two keys like a player would.
+func AutoPlayFrames(osu *OsuFile, mods Mods) []ReplayFrame {
+	var frames []ReplayFrame
+	add := func(t int64, x, y float64, keys int) {
+		if mods.Has(ModHardRock) {
+			y = PlayfieldHeight - y
+		}
+		if n := len(frames); n > 0 && t <= frames[n-1].TimeMsec {
+			t = frames[n-1].TimeMsec + 1
+		}
+		frames = append(frames, ReplayFrame{TimeMsec: t, X: x, Y: y, Keys: keys})
+	}
+
+	add(0, PlayfieldWidth/2, PlayfieldHeight/2, 0)
+	keys := []int{KeyMouse1 | KeyK1, KeyMouse2 | KeyK2}
+	for i := range osu.HitObjects {
+		h := &osu.HitObjects[i]
+		key := keys[i%2]
+		start := int64(h.TimeMsec)
+		end := int64(osu.EndTimeOf(h))
+		x, y := float64(h.X), float64(h.Y)
+
+		// Move onto the object just before pressing it
+		add(start-1, x, y, 0)
+		add(start, x, y, key)
+		switch {
+		case h.IsSlider():
+			for t := start + autoPlayFrameMsec; t < end; t += autoPlayFrameMsec {
+				sx, sy := sliderPosition(osu, h, float64(t))
+				add(t, sx, sy, key)
+			}
+			x, y = sliderPosition(osu, h, float64(end))
+		case h.IsSpinner():
+			const spinRadius = 50
+			for t := start; t < end; t += autoPlayFrameMsec {
+				angle := float64(t-start) / 50
+				add(t, x+spinRadius*math.Cos(angle), y+spinRadius*math.Sin(angle), key)
+			}
+		}
+		add(end, x, y, key)
+		add(end+1, x, y, 0)
+	}
+
+	for i := range frames {
+		if i == 0 {
+			frames[i].TimeDeltaMsec = frames[i].TimeMsec
+		} else {
+			frames[i].TimeDeltaMsec = frames[i].TimeMsec - frames[i-1].TimeMsec
+		}
+	}
+	return frames
+}
+
+// Returns the approximate position of the slider ball at the given time by
+// following the straight lines between the slider's control points.
+func sliderPosition(osu *OsuFile, h *HitObject, timeMsec float64) (float64, float64) {
+	points := append([][2]int{{h.X, h.Y}}, h.CurvePoints...)
+	slides := h.Slides
+	if slides < 1 {
+		slides = 1
+	}
+	start := float64(h.TimeMsec)
+	spanDuration := (float64(osu.EndTimeOf(h)) - start) / float64(slides)
+	if spanDuration <= 0 || len(points) < 2 {
+		return float64(h.X), float64(h.Y)
+	}
+
+	progress := (timeMsec - start) / spanDuration
+	span := int(progress)
+	if span >= slides {
+		span = slides - 1
+	}
+	progress = math.Min(math.Max(progress-float64(span), 0), 1)
+	if span%2 == 1 {
+		progress = 1 - progress
+	}
+
+	var lengths []float64
+	total := 0.0
+	for i := 1; i < len(points); i++ {
+		l := math.Hypot(float64(points[i][0]-points[i-1][0]), float64(points[i][1]-points[i-1][1]))
+		lengths = append(lengths, l)
+		total += l
+	}
+	target := progress * math.Min(total, h.Length)
+	for i, l := range lengths {
+		if target <= l || i == len(lengths)-1 {
+			f := 0.0
+			if l > 0 {
+				f = math.Min(target/l, 1)
+			}
+			a, b := points[i], points[i+1]
+			return float64(a[0]) + f*float64(b[0]-a[0]), float64(a[1]) + f*float64(b[1]-a[1])
+		}
+		target -= l
+	}
+	return float64(h.X), float64(h.Y)
+}
+
+// Returns the highest combo reached and whether it was a full combo
+func (this *ReplayAnalysis) maxCombo(osu *OsuFile) (int, bool) {
+	combo, best := 0, 0
+	perfect := true
+	timing := osu.TimingMap()
+	for _, hit := range this.Hits {
+		if hit.Result == HitMiss || hit.SliderBreak {
+			perfect = false
+			combo = 0
+			if hit.Result == HitMiss {
+				continue
+			}
+		}
+		combo += osu.comboOf(&osu.HitObjects[hit.Index], timing)
+		if combo > best {
+			best = combo
+		}
+	}
+	return best, perfect
+}
@@ -0,0 +1,109 @@
+package gosu
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Locates the replays the client stores for the local scores in scores.db.
+// The client saves the replay of every local score as
+// "Data/r/<beatmap md5>-<windows file time>.osr" inside the install
+// directory, where the windows file time is derived from the score's
+// TimestampOfReplayWindowTicks.
+
+// The number of .NET ticks between 0001-01-01 and 1601-01-01, the epoch of
+// windows file times.
+const ticksAtFileTimeEpoch = 504911232000000000
+
+// Returns the path of the replay file for the score, relative to the osu!
+// install directory.
+func (this *ScoresDbBeatMapScore) ReplayFileName() string {
+	fileTime := uint64(this.TimestampOfReplayWindowTicks) - ticksAtFileTimeEpoch
+	return filepath.Join("Data", "r", fmt.Sprintf("%s-%d.osr", this.Md5Hash.Text, fileTime))
+}
+
+// The replay file of a single score
+type ReplayLink struct {
+	Score *ScoresDbBeatMapScore
+	// The absolute path of where the replay should be
+	Path string
+	// Whether the replay file exists
+	Found bool
+	// Why the replay's header does not match the score, empty if it does or
+	// if the replay was not found.
+	Mismatch string
+	// The error reading the replay's header, if it exists but can not be
+	// read or decoded.
+	Err error
+}
+
+// Resolve every score in the scores.db to its replay file in the install
+// directory and check that the replay's header matches the score. Only the
+// headers of the replays are decoded. A replay which can not be read does
+// not stop the others from being resolved, its error is kept in its link.
+// Args:
+//   installDir: The osu! install directory, which contains the Data folder
+//   scores: The decoded scores.db of the install
+func ResolveReplays(installDir string, scores *ScoresDb) []ReplayLink {
+	var links []ReplayLink
+	for i := range scores.Beatmaps {
+		for j := range scores.Beatmaps[i].Scores {
+			score := &scores.Beatmaps[i].Scores[j]
+			link := ReplayLink{
+				Score: score,
+				Path:  filepath.Join(installDir, score.ReplayFileName()),
+			}
+
+			replay, err := ReadReplayHeader(link.Path)
+			switch {
+			case os.IsNotExist(err):
+			case err != nil:
+				link.Found = true
+				link.Err = err
+			default:
+				link.Found = true
+				link.Mismatch = replayMismatch(replay, score)
+			}
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// Returns the scores whose replay file is missing from the install directory
+func MissingReplays(links []ReplayLink) []*ScoresDbBeatMapScore {
+	var missing []*ScoresDbBeatMapScore
+	for _, link := range links {
+		if !link.Found {
+			missing = append(missing, link.Score)
+		}
+	}
+	return missing
+}
+
+// Returns a description of the first difference between the replay header
+// and the score, or an empty string if they match.
+func replayMismatch(replay *Replay, score *ScoresDbBeatMapScore) string {
+	switch {
+	case replay.GameplayMode != score.GameplayMode:
+		return fmt.Sprintf("mode is %d, score has %d", replay.GameplayMode, score.GameplayMode)
+	case replay.BeatmapMd5Hash.Text != score.Md5Hash.Text:
+		return fmt.Sprintf("beatmap is %s, score has %s",
+			replay.BeatmapMd5Hash.Text, score.Md5Hash.Text)
+	case replay.ReplayMd5Hash.Text != score.ReplayMd5Hash.Text:
+		return fmt.Sprintf("replay hash is %s, score has %s",
+			replay.ReplayMd5Hash.Text, score.ReplayMd5Hash.Text)
+	case replay.PlayerName.Text != score.PlayerName.Text:
+		return fmt.Sprintf("player is %s, score has %s",
+			replay.PlayerName.Text, score.PlayerName.Text)
+	case replay.HitCounts() != score.HitCounts():
+		return fmt.Sprintf("hit counts are %+v, score has %+v",
+			replay.HitCounts(), score.HitCounts())
+	case replay.ReplayScore != score.ReplayScore:
+		return fmt.Sprintf("score is %d, score has %d", replay.ReplayScore, score.ReplayScore)
+	case replay.Mods != score.Mods:
+		return fmt.Sprintf("mods are %s, score has %s", Mods(replay.Mods), Mods(score.Mods))
+	}
+	return ""
+}
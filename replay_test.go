@@ -0,0 +1,238 @@
+package gosu
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/d4l3k/messagediff"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// Build frames which press on every hit object of the beatmap with the given
+// offsets (in msec) and hold the key until the object ends.
+func framesHitting(osu *OsuFile, offsets []int64) []ReplayFrame {
+	var frames []ReplayFrame
+	add := func(t int64, x, y float64, keys int) {
+		delta := t
+		if len(frames) > 0 {
+			delta = t - frames[len(frames)-1].TimeMsec
+		}
+		frames = append(frames, ReplayFrame{delta, t, x, y, keys})
+	}
+	add(0, 256, 192, 0)
+	for i := range osu.HitObjects {
+		h := &osu.HitObjects[i]
+		x, y := float64(h.X), float64(h.Y)
+		press := int64(h.TimeMsec) + offsets[i]
+		add(press-1, x, y, 0)
+		add(press, x, y, KeyMouse1|KeyK1)
+		add(int64(osu.EndTimeOf(h))+1, x, y, KeyMouse1|KeyK1)
+		add(int64(osu.EndTimeOf(h))+2, x, y, 0)
+	}
+	return frames
+}
+
+// Returns the test song with its spinner moved to start after the slider
+// before it has ended, so that autoplay can hit every object.
+func readReplayTestOsuFile(t *testing.T) *OsuFile {
+	osu, err := ReadOsuFile(testOsuFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range osu.HitObjects {
+		if osu.HitObjects[i].IsSpinner() {
+			osu.HitObjects[i].TimeMsec = 7250
+		}
+	}
+	return osu
+}
+
+func TestAnalyzeFrames(t *testing.T) {
+	osu := readReplayTestOsuFile(t)
+	// OD 6: the 300/100/50 windows are 44/92/140 msec
+	offsets := []int64{-10, 10, -10, 10, -10, 10, 60, -120, 0, 0, 0, 10, -10, 500}
+	analysis := AnalyzeFrames(osu, framesHitting(osu, offsets), Mods(0))
+
+	want := HitCounts{Count300: 11, Count100: 1, Count50: 1, CountMiss: 1,
+		CountGeki: 3, CountKatu: 0}
+	if mismatches := analysis.CompareCounts(want); len(mismatches) != 0 {
+		t.Errorf("Unexpected hit counts: %v", mismatches)
+	}
+
+	// Errors: circles and slider heads only, excluding the spinner and miss
+	errs := []float64{-10, 10, -10, 10, -10, 10, 60, -120, 0, 0, 10, -10}
+	mean, variance := 0.0, 0.0
+	for _, e := range errs {
+		mean += e / float64(len(errs))
+	}
+	for _, e := range errs {
+		variance += (e - mean) * (e - mean) / float64(len(errs))
+	}
+	if ur := math.Sqrt(variance) * 10; math.Abs(analysis.UnstableRate-ur) > 1e-9 {
+		t.Errorf("Got unstable rate %v, want %v", analysis.UnstableRate, ur)
+	}
+	if analysis.MeanEarlyMsec != -32 || analysis.MeanLateMsec != 100.0/7 {
+		t.Errorf("Got early/late %v/%v", analysis.MeanEarlyMsec, analysis.MeanLateMsec)
+	}
+	if analysis.SliderBreaks != 0 {
+		t.Errorf("Got %d slider breaks, want 0", analysis.SliderBreaks)
+	}
+}
+
+func TestReplayFrames(t *testing.T) {
+	var compressed bytes.Buffer
+	writer, err := lzma.NewWriter(&compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Write([]byte("0|256|-500|0,-1|256|-500|0,10|100.5|50|5,16|120|60|0,-12345|0|0|1234,"))
+	writer.Close()
+
+	replay := Replay{NumReplayData: Int(compressed.Len())}
+	for _, b := range compressed.Bytes() {
+		replay.ReplayData = append(replay.ReplayData, Byte(b))
+	}
+	frames, err := replay.Frames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frames) != 4 {
+		t.Fatalf("Expected 4 frames, got %v", frames)
+	}
+	if frames[2].TimeMsec != 9 || frames[2].X != 100.5 || !frames[2].Pressed() {
+		t.Errorf("Unexpected frame %+v", frames[2])
+	}
+	if frames[3].TimeMsec != 25 || frames[3].Pressed() {
+		t.Errorf("Unexpected frame %+v", frames[3])
+	}
+}
+
+func TestReplayBuilderAutoPlay(t *testing.T) {
+	osu := readReplayTestOsuFile(t)
+	builder := ReplayBuilder{
+		Beatmap:    osu,
+		BeatmapMd5: "d41d8cd98f00b204e9800998ecf8427e",
+		PlayerName: "gosu",
+		Mods:       ModHidden | ModHardRock,
+		Score:      12345,
+		Timestamp:  time.Date(2018, 2, 4, 0, 0, 0, 0, time.UTC),
+		Version:    Int(20171227),
+	}
+	replay, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Num300 != 14 || replay.NumMiss != 0 || replay.IsPerfectCombo != 1 {
+		t.Errorf("Expected a perfect autoplay, got %+v", replay.HitCounts())
+	}
+	if replay.MaxCombo != 19 {
+		t.Errorf("Got max combo %d, want 19", replay.MaxCombo)
+	}
+	if err := replay.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	dir, err := ioutil.TempDir("", "gosu-replay-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "autoplay.osr")
+	if err := WriteReplayFile(path, replay); err != nil {
+		t.Fatal(err)
+	}
+	final, err := ReadReplayFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff, equal := messagediff.PrettyDiff(replay, final); !equal {
+		t.Errorf("Marshal/Unmarshal failed.\n%s", diff)
+	}
+
+	analysis, err := AnalyzeReplay(osu, final)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.UnstableRate != 0 || analysis.SliderBreaks != 0 {
+		t.Errorf("Expected a perfect analysis, got UR %v and %d slider breaks",
+			analysis.UnstableRate, analysis.SliderBreaks)
+	}
+	if mismatches := analysis.CompareCounts(final.HitCounts()); len(mismatches) != 0 {
+		t.Errorf("Analysis disagrees with the replay: %v", mismatches)
+	}
+}
+
+func TestResolveReplays(t *testing.T) {
+	file, err := os.Open("data/scores.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var scores ScoresDb
+	if err := scores.UnmarshalOsuBinary(file, Int(20171227)); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "gosu-install-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.MkdirAll(filepath.Join(dir, "Data", "r"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write a matching replay for the first score and a mismatching one for
+	// the second.
+	for i, score := range scores.Beatmaps[0:2] {
+		s := score.Scores[0]
+		replay := &Replay{
+			GameplayMode:   s.GameplayMode,
+			Version:        s.Version,
+			BeatmapMd5Hash: s.Md5Hash,
+			PlayerName:     s.PlayerName,
+			ReplayMd5Hash:  s.ReplayMd5Hash,
+			Num300:         s.Num300,
+			Num200:         s.Num200,
+			Num50:          s.Num50,
+			NumMax300:      s.NumMax300,
+			Num100:         s.Num100,
+			NumMiss:        s.NumMiss,
+			ReplayScore:    s.ReplayScore,
+			Mods:           s.Mods,
+			TimestampTicks: s.TimestampOfReplayWindowTicks,
+		}
+		if i == 1 {
+			replay.NumMiss++
+		}
+		if err := WriteReplayFile(filepath.Join(dir, s.ReplayFileName()), replay); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A corrupt replay for the third score does not hide the others
+	corrupt := filepath.Join(dir, scores.Beatmaps[2].Scores[0].ReplayFileName())
+	if err := ioutil.WriteFile(corrupt, []byte{0, 1, 2}, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	links := ResolveReplays(dir, &scores)
+	if !links[0].Found || links[0].Mismatch != "" {
+		t.Errorf("Expected the first replay to match, got %+v", links[0])
+	}
+	if !links[1].Found || links[1].Mismatch == "" {
+		t.Errorf("Expected the second replay to mismatch, got %+v", links[1])
+	}
+	third := len(scores.Beatmaps[0].Scores) + len(scores.Beatmaps[1].Scores)
+	if !links[third].Found || links[third].Err == nil {
+		t.Errorf("Expected the third replay to fail to decode, got %+v", links[third])
+	}
+	if missing := MissingReplays(links); len(missing) != len(links)-3 {
+		t.Errorf("Expected %d missing replays, got %d", len(links)-3, len(missing))
+	}
+}
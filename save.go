@@ -0,0 +1,305 @@
+package gosu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Saving DB files without the risk of losing them. The new file is written
+// next to the old one and only replaces it once it is complete and decodes
+// back to the same bytes, and the old file is kept as a timestamped backup.
+
+// The number of backups SaveFile keeps
+const DefaultBackups = 5
+
+// The layout of the timestamp in backup file names. It sorts by time.
+const backupTimeLayout = "20060102T150405.000000000"
+
+const backupExtension = ".bak"
+
+// Options of SaveFileWithOptions
+type SaveOptions struct {
+	// The number of backups of the file to keep, older backups are deleted.
+	// With 0 no backup is made and the existing backups are left alone.
+	Backups int
+	// Write the file even if an osu! client is running. The client overwrites
+	// osu!.db, collection.db, scores.db, presence.db and its cfg files when
+	// it exits, so saving them while it runs is refused by default.
+	Force bool
+}
+
+// The options SaveFile uses
+var DefaultSaveOptions = SaveOptions{Backups: DefaultBackups}
+
+// Save the db to the path, keeping a backup of the file it replaces.
+// See SaveFileWithOptions.
+func SaveFile(path string, db BinaryOsuCodec, version Int) error {
+	return SaveFileWithOptions(path, db, version, DefaultSaveOptions)
+}
+
+// Save the db to the path. The db must pass validation. It is written to a
+// temporary file in the same directory, synced to disk and decoded again to
+// check it, before the temporary file is renamed over the path. If the path
+// already exists it is kept as a backup named "<path>.<timestamp>.bak".
+// Returns a ClientRunningError if the file is one of the client's DBs and a
+// client is running, unless the options force the write. Returns a
+// PruneBackupsError if the file was saved but its old backups were not all
+// deleted.
+// Args:
+//   path: Where to save the db
+//   db: Pointer to the DB to save, e.g. a *OsuDb
+//   version: The version to write the db as
+//   options: How many backups to keep and whether to force the write
+func SaveFileWithOptions(path string, db BinaryOsuCodec, version Int, options SaveOptions) error {
+	if !options.Force {
+		if err := checkClientNotRunning(path); err != nil {
+			return err
+		}
+	}
+	var buf bytes.Buffer
+	if err := MarshalValidated(db, &buf, version); err != nil {
+		return err
+	}
+	return replaceFile(path, buf.Bytes(), options, func(written []byte) error {
+		return verifyEncoding(db, written, buf.Bytes(), version)
+	})
+}
+
+// Check that the written bytes decode into a value which encodes to the
+// expected bytes again.
+func verifyEncoding(db BinaryOsuCodec, written []byte, expected []byte, version Int) error {
+	decoded := reflect.New(reflect.TypeOf(db).Elem()).Interface().(BinaryOsuCodec)
+	decoder := NewDecoder(written, version)
+	if err := decoder.Decode(decoded); err != nil {
+		return fmt.Errorf("The written file does not decode: %v", err)
+	}
+	if decoder.Offset() != len(written) {
+		return fmt.Errorf("The written file has %d trailing bytes", len(written)-decoder.Offset())
+	}
+	var encoded bytes.Buffer
+	if err := decoded.MarshalOsuBinary(&encoded, version); err != nil {
+		return err
+	}
+	if !bytes.Equal(encoded.Bytes(), expected) {
+		return errors.New("The written file does not decode to the saved DB")
+	}
+	return nil
+}
+
+// Atomically replace the file at the path with the data, backing up the old
+// file first.
+// Args:
+//   path: The file to replace
+//   data: The new contents of the file
+//   options: How many backups to keep
+//   verify: Called with the contents of the temporary file as read back
+//     from the disk, the file is only replaced if it returns nil
+func replaceFile(path string, data []byte, options SaveOptions, verify func([]byte) error) error {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	mode := os.FileMode(0644)
+	info, err := os.Stat(path)
+	exists := err == nil
+	if exists {
+		mode = info.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	temp, err := ioutil.TempFile(dir, name+".tmp")
+	if err != nil {
+		return err
+	}
+	// Removing the temporary file fails once it is renamed, which is fine
+	defer os.Remove(temp.Name())
+	if err := writeAndSync(temp, data, mode); err != nil {
+		return err
+	}
+
+	if verify != nil {
+		written, err := ioutil.ReadFile(temp.Name())
+		if err != nil {
+			return err
+		}
+		if err := verify(written); err != nil {
+			return err
+		}
+	}
+
+	if exists && options.Backups > 0 {
+		if err := backupFile(path); err != nil {
+			return fmt.Errorf("Failed to back up %s: %v", path, err)
+		}
+	}
+	if err := os.Rename(temp.Name(), path); err != nil {
+		return err
+	}
+	syncDir(dir)
+
+	if exists && options.Backups > 0 {
+		if err := pruneBackups(path, options.Backups); err != nil {
+			return PruneBackupsError{path, err}
+		}
+	}
+	return nil
+}
+
+// Returned when a file was saved, but deleting its oldest backups failed
+type PruneBackupsError struct {
+	Path string
+	Err  error
+}
+
+func (this PruneBackupsError) Error() string {
+	return fmt.Sprintf("Saved %s but failed to delete its old backups: %v", this.Path, this.Err)
+}
+
+func writeAndSync(file *os.File, data []byte, mode os.FileMode) error {
+	_, err := file.Write(data)
+	if err == nil {
+		err = file.Chmod(mode)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// Sync the directory so that a rename in it survives a crash. Not every
+// platform supports this, so errors are ignored.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// Copy the file at the path to a new backup. The backup is a hard link
+// where the file system supports it, since the file is replaced and not
+// changed in place.
+func backupFile(path string) error {
+	backup := fmt.Sprintf("%s.%s%s", path, time.Now().UTC().Format(backupTimeLayout), backupExtension)
+	if err := os.Link(path, backup); err == nil {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(backup)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if err := writeAndSync(temp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), backup)
+}
+
+// Returns the backups of the file at the path, newest first
+func Backups(path string) ([]string, error) {
+	dir, name := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, info := range infos {
+		if isBackupOf(info.Name(), name) {
+			backups = append(backups, filepath.Join(dir, info.Name()))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+// Returns whether the file name is of a backup of the named file
+func isBackupOf(fileName string, name string) bool {
+	if !strings.HasPrefix(fileName, name+".") || !strings.HasSuffix(fileName, backupExtension) {
+		return false
+	}
+	timestamp := strings.TrimSuffix(strings.TrimPrefix(fileName, name+"."), backupExtension)
+	_, err := time.Parse(backupTimeLayout, timestamp)
+	return err == nil
+}
+
+// Delete all but the newest backups of the file
+func pruneBackups(path string, keep int) error {
+	backups, err := Backups(path)
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(backups[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Roll the file at the path back to one of its backups.
+// See RestoreWithOptions.
+func Restore(path string, backup string) error {
+	return RestoreWithOptions(path, backup, DefaultSaveOptions)
+}
+
+// Roll the file at the path back to one of its backups. The current file is
+// itself backed up first, so a restore can be undone. Like SaveFile, this is
+// refused while an osu! client is running unless the options force it.
+// Args:
+//   path: The file to restore
+//   backup: The backup to restore, one of Backups(path). If empty the
+//     newest backup is restored.
+//   options: Whether to force the restore. No backup is deleted by it.
+func RestoreWithOptions(path string, backup string, options SaveOptions) error {
+	if !options.Force {
+		if err := checkClientNotRunning(path); err != nil {
+			return err
+		}
+	}
+	backups, err := Backups(path)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf("%s has no backups", path)
+	}
+	if backup == "" {
+		backup = backups[0]
+	} else if !containsPath(backups, backup) {
+		return fmt.Errorf("%s is not a backup of %s", backup, path)
+	}
+	data, err := ioutil.ReadFile(backup)
+	if err != nil {
+		return err
+	}
+	// Keep one more backup than there is, so none is lost by the restore
+	options.Backups = len(backups) + 1
+	return replaceFile(path, data, options, nil)
+}
+
+func containsPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if filepath.Clean(p) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package gosu
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "collection.db")
+
+	original, err := ioutil.ReadFile("data/collection.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := new(CollectionDb)
+	if err := NewDecoder(original, Int(20171227)).Decode(db); err != nil {
+		t.Fatal(err)
+	}
+
+	options := SaveOptions{Backups: 2}
+	for i := 0; i < 4; i++ {
+		db.Collections[0].Name = NewString(string(rune('a' + i)))
+		if err := SaveFileWithOptions(path, db, db.Version, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, err := Backups(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups, got %v", backups)
+	}
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 3 {
+		t.Errorf("Expected only the file and its backups, got %d files", len(infos))
+	}
+
+	// Only backups of the file can be restored
+	if err := Restore(path, "data/collection.db"); err == nil {
+		t.Error("Expected restoring a file which is not a backup to fail")
+	}
+
+	// The newest backup is the save before the last
+	if err := Restore(path, ""); err != nil {
+		t.Fatal(err)
+	}
+	restored := new(CollectionDb)
+	if err := DecodeFile(path, restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored.Collections[0].Name.Text != "c" {
+		t.Errorf("Expected the restored collection to be named c, got %q",
+			restored.Collections[0].Name.Text)
+	}
+
+	// An invalid DB is refused and leaves the file alone
+	before, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.NumCollections++
+	if err := SaveFile(path, db, db.Version); err == nil {
+		t.Error("Expected saving an invalid DB to fail")
+	}
+	after, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("A failed save changed the file")
+	}
+}
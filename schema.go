@@ -0,0 +1,276 @@
+package gosu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A description of the binary formats built from the DB structs and their
+// tags, so that tools in other languages can decode the files the same way.
+// Every value is little endian. The wire types are
+//   u1, u2, u4, u8: unsigned integers of 1, 2, 4 and 8 bytes
+//   f4, f8: IEEE 754 floats of 4 and 8 bytes
+//   uleb128: an unsigned LEB128 variable length integer
+//   string: a u1 which is 0x0b when a uleb128 length and that many bytes of
+//     UTF-8 text follow, and 0x00 for an empty string
+//   datetime: a u8 of .NET ticks, 100ns intervals since 0001-01-01
+// Any other type is the name of a struct described in the same schema.
+
+// A single field of a struct in a Schema
+type SchemaField struct {
+	Name string `json:"name"`
+	// The wire type, see above
+	Type string `json:"type"`
+	// The name of the Go type of the field, e.g. "Grade"
+	GoType string `json:"go_type"`
+	// For repeated fields, the name of the earlier field holding the number
+	// of elements.
+	CountField string `json:"count_field,omitempty"`
+	// The field is only present from this version on (inclusive)
+	SinceVersion uint32 `json:"since_version,omitempty"`
+	// The field is only present up to this version (inclusive)
+	UntilVersion uint32 `json:"until_version,omitempty"`
+	// The osu-check validation of the field, see ValidateAny
+	Check string `json:"check,omitempty"`
+}
+
+// A struct in a Schema
+type SchemaType struct {
+	Name   string        `json:"name"`
+	Fields []SchemaField `json:"fields"`
+}
+
+// The format of a DB file
+type Schema struct {
+	// The name of the struct the file decodes into
+	Root   string `json:"root"`
+	Endian string `json:"endian"`
+	// The root struct followed by every struct it uses, in order of first use
+	Types []SchemaType `json:"types"`
+}
+
+// Build the schema of the format db decodes
+// Args:
+//   db: A DB struct or a pointer to one, e.g. OsuDb{}
+func SchemaOf(db interface{}) (*Schema, error) {
+	rootType := reflect.TypeOf(db)
+	if rootType.Kind() == reflect.Ptr {
+		rootType = rootType.Elem()
+	}
+	if rootType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("Can not build the schema of a %s", rootType)
+	}
+	schema := &Schema{Root: rootType.Name(), Endian: "little"}
+	seen := map[reflect.Type]bool{rootType: true}
+	if err := schema.addType(rootType, seen); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// Append the struct and the structs it uses which are not yet seen
+func (this *Schema) addType(structType reflect.Type, seen map[reflect.Type]bool) error {
+	index := len(this.Types)
+	this.Types = append(this.Types, SchemaType{Name: structType.Name()})
+
+	var nested []reflect.Type
+	var fields []SchemaField
+	for i := 0; i < structType.NumField(); i++ {
+		fieldType := structType.Field(i)
+		field := SchemaField{
+			Name:   fieldType.Name,
+			GoType: fieldType.Type.Name(),
+			Check:  fieldType.Tag.Get("osu-check"),
+		}
+		for _, tag := range []struct {
+			Name    string
+			Version *uint32
+		}{{"osu-start", &field.SinceVersion}, {"osu-end", &field.UntilVersion}} {
+			if value, ok := fieldType.Tag.Lookup(tag.Name); ok {
+				parsed, err := strconv.ParseUint(value, 10, 32)
+				if err != nil {
+					return err
+				}
+				*tag.Version = uint32(parsed)
+			}
+		}
+
+		elemType := fieldType.Type
+		if elemType.Kind() == reflect.Slice {
+			elemType = elemType.Elem()
+			field.GoType = "[]" + elemType.Name()
+			field.CountField = "Num" + fieldType.Name
+			if _, ok := structType.FieldByName(field.CountField); !ok {
+				return fmt.Errorf("%s.%s has no %s count", structType.Name(),
+					fieldType.Name, field.CountField)
+			}
+		}
+		wireType, ok := wireTypeOf(elemType)
+		if !ok {
+			return fmt.Errorf("%s.%s has no wire type", structType.Name(), fieldType.Name)
+		}
+		field.Type = wireType
+		if elemType.Kind() == reflect.Struct && wireType == elemType.Name() && !seen[elemType] {
+			seen[elemType] = true
+			nested = append(nested, elemType)
+		}
+		fields = append(fields, field)
+	}
+	this.Types[index].Fields = fields
+
+	for _, t := range nested {
+		if err := this.addType(t, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var ulebType = reflect.TypeOf(ULEB128(0))
+
+func wireTypeOf(t reflect.Type) (string, bool) {
+	switch t {
+	case stringType:
+		return "string", true
+	case dateTimeType:
+		return "datetime", true
+	case ulebType:
+		return "uleb128", true
+	}
+	switch t.Kind() {
+	case reflect.Uint8:
+		return "u1", true
+	case reflect.Uint16:
+		return "u2", true
+	case reflect.Uint32:
+		return "u4", true
+	case reflect.Uint64:
+		return "u8", true
+	case reflect.Float32:
+		return "f4", true
+	case reflect.Float64:
+		return "f8", true
+	case reflect.Struct:
+		return t.Name(), true
+	}
+	return "", false
+}
+
+// Write the schema as indented JSON
+func (this *Schema) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(this)
+}
+
+// Write the schema as a Kaitai Struct definition (.ksy). The uleb128 lengths
+// use the vlq_base128_le type from the Kaitai Struct format gallery.
+// Args:
+//   w: Where to write the definition
+//   extension: The file extension of the format, e.g. "db" or "osr"
+func (this *Schema) WriteKaitai(w io.Writer, extension string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "meta:\n  id: %s\n  file-extension: %s\n  endian: le\n", snakeCase(this.Root), extension)
+	b.WriteString("  imports:\n    - /common/vlq_base128_le\n")
+
+	root := this.Types[0]
+	b.WriteString("seq:\n")
+	writeKaitaiFields(&b, "", root.Fields)
+
+	b.WriteString("types:\n")
+	for _, t := range this.Types[1:] {
+		fmt.Fprintf(&b, "  %s:\n    seq:\n", snakeCase(t.Name))
+		writeKaitaiFields(&b, "    ", t.Fields)
+	}
+	b.WriteString(`  osu_string:
+    seq:
+      - id: cond
+        type: u1
+      - id: len
+        type: vlq_base128_le
+        if: cond == 0x0b
+      - id: text
+        type: str
+        size: len.value
+        encoding: UTF-8
+        if: cond == 0x0b
+`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeKaitaiFields(b *strings.Builder, indent string, fields []SchemaField) {
+	for _, field := range fields {
+		fmt.Fprintf(b, "%s  - id: %s\n", indent, snakeCase(field.Name))
+		attr := func(format string, args ...interface{}) {
+			fmt.Fprintf(b, "%s    %s\n", indent, fmt.Sprintf(format, args...))
+		}
+
+		if field.CountField != "" && field.Type == "u1" {
+			// Raw byte arrays
+			attr("size: %s", snakeCase(field.CountField))
+		} else {
+			switch field.Type {
+			case "string":
+				attr("type: osu_string")
+			case "datetime":
+				attr("type: u8")
+				attr("doc: .NET ticks")
+			case "uleb128":
+				attr("type: vlq_base128_le")
+			default:
+				if isKaitaiBuiltin(field.Type) {
+					attr("type: %s", field.Type)
+				} else {
+					attr("type: %s", snakeCase(field.Type))
+				}
+			}
+			if field.CountField != "" {
+				attr("repeat: expr")
+				attr("repeat-expr: %s", snakeCase(field.CountField))
+			}
+		}
+
+		var conditions []string
+		if field.SinceVersion != 0 {
+			conditions = append(conditions, fmt.Sprintf("_root.version >= %d", field.SinceVersion))
+		}
+		if field.UntilVersion != 0 {
+			conditions = append(conditions, fmt.Sprintf("_root.version <= %d", field.UntilVersion))
+		}
+		if len(conditions) > 0 {
+			attr("if: %s", strings.Join(conditions, " and "))
+		}
+	}
+}
+
+func isKaitaiBuiltin(wireType string) bool {
+	switch wireType {
+	case "u1", "u2", "u4", "u8", "f4", "f8":
+		return true
+	}
+	return false
+}
+
+// Convert a Go name to the snake case Kaitai uses, e.g. "NumBeatmaps" to
+// "num_beatmaps" and "BPM" to "bpm".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package gosu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSchemaOf(t *testing.T) {
+	schema, err := SchemaOf(OsuDb{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, schemaType := range schema.Types {
+		names = append(names, schemaType.Name)
+	}
+	if strings.Join(names, ",") != "OsuDb,BeatMap,IntDoublePair,TimingPoint" {
+		t.Errorf("Unexpected types %v", names)
+	}
+
+	fields := make(map[string]SchemaField)
+	for _, field := range schema.Types[1].Fields {
+		fields[field.Name] = field
+	}
+	expected := []SchemaField{
+		{Name: "Md5", Type: "string", GoType: "String", Check: "md5"},
+		{Name: "ApproachRateByte", Type: "u1", GoType: "Byte", UntilVersion: 20140609},
+		{Name: "OsuStandardStarRating", Type: "IntDoublePair", GoType: "[]IntDoublePair",
+			CountField: "NumOsuStandardStarRating", SinceVersion: 20140609},
+		{Name: "GradeOsuStandard", Type: "u1", GoType: "Grade", Check: "grade"},
+		{Name: "LastModTimeTicks", Type: "u8", GoType: "Long"},
+	}
+	for _, field := range expected {
+		if fields[field.Name] != field {
+			t.Errorf("Expected %+v, got %+v", field, fields[field.Name])
+		}
+	}
+
+	var ksy bytes.Buffer
+	if err := schema.WriteKaitai(&ksy, "db"); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"  id: osu_db\n",
+		"  - id: beatmaps\n    type: beat_map\n    repeat: expr\n    repeat-expr: num_beatmaps\n",
+		"      - id: approach_rate_byte\n        type: u1\n        if: _root.version <= 20140609\n",
+	} {
+		if !strings.Contains(ksy.String(), line) {
+			t.Errorf("Expected the .ksy to contain\n%s\ngot\n%s", line, ksy.String())
+		}
+	}
+
+	replay, err := SchemaOf(&Replay{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ksy.Reset()
+	if err := replay.WriteKaitai(&ksy, "osr"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ksy.String(), "  - id: replay_data\n    size: num_replay_data\n") {
+		t.Errorf("Expected the replay data to be a byte array:\n%s", ksy.String())
+	}
+}
@@ -0,0 +1,222 @@
+package gosu
+
+import (
+	"sort"
+	"time"
+)
+
+// Helpers for editing the local scores stored in scores.db
+
+// Merge the scores.db of several installs into a new ScoresDb. None of the
+// given dbs are modified.
+// Scores of the same beatmap are combined under a single entry. A score is
+// dropped as a duplicate when an earlier one has the same non-zero
+// OnlineScoreId, or the same non-empty replay hash. The beatmaps are kept in
+// the order they are first seen and the scores of each beatmap are sorted
+// the way the client expects, highest score first. The merged db takes the
+// highest version of the given dbs.
+func MergeScoresDb(dbs ...*ScoresDb) *ScoresDb {
+	merged := &ScoresDb{}
+	index := make(map[string]int)
+	seen := make(map[string]*scoreSet)
+	for _, db := range dbs {
+		if db == nil {
+			continue
+		}
+		if db.Version > merged.Version {
+			merged.Version = db.Version
+		}
+		for i := range db.Beatmaps {
+			md5 := db.Beatmaps[i].Md5Hash.Text
+			j, ok := index[md5]
+			if !ok {
+				j = len(merged.Beatmaps)
+				index[md5] = j
+				seen[md5] = newScoreSet()
+				merged.Beatmaps = append(merged.Beatmaps, ScoresDbBeatMap{
+					Md5Hash: db.Beatmaps[i].Md5Hash,
+				})
+			}
+			beatmap := &merged.Beatmaps[j]
+			for _, score := range db.Beatmaps[i].Scores {
+				if seen[md5].Contains(&score) {
+					continue
+				}
+				seen[md5].Add(&score)
+				beatmap.Scores = append(beatmap.Scores, score)
+			}
+		}
+	}
+	for i := range merged.Beatmaps {
+		merged.Beatmaps[i].SortScores()
+	}
+	merged.NumBeatmaps = Int(len(merged.Beatmaps))
+	return merged
+}
+
+// The scores of a beatmap, for deduplication. A score is a duplicate of
+// another when both have the same non-zero OnlineScoreId, or both have the
+// same non-empty replay hash. A local score has no OnlineScoreId until the
+// server assigns one, so the same score can appear with and without its id
+// and matching on either the id or the replay hash is enough.
+type scoreSet struct {
+	ids    map[Long]bool
+	hashes map[string]bool
+}
+
+func newScoreSet() *scoreSet {
+	return &scoreSet{ids: make(map[Long]bool), hashes: make(map[string]bool)}
+}
+
+// Returns whether the set holds a duplicate of the score
+func (this *scoreSet) Contains(score *ScoresDbBeatMapScore) bool {
+	return (score.OnlineScoreId != 0 && this.ids[score.OnlineScoreId]) ||
+		(score.ReplayMd5Hash.Text != "" && this.hashes[score.ReplayMd5Hash.Text])
+}
+
+func (this *scoreSet) Add(score *ScoresDbBeatMapScore) {
+	if score.OnlineScoreId != 0 {
+		this.ids[score.OnlineScoreId] = true
+	}
+	if score.ReplayMd5Hash.Text != "" {
+		this.hashes[score.ReplayMd5Hash.Text] = true
+	}
+}
+
+// Sort the scores highest first, like the client's local leaderboard, and
+// update NumScores to match. Ties keep the older score first.
+func (this *ScoresDbBeatMap) SortScores() {
+	sort.SliceStable(this.Scores, func(i, j int) bool {
+		a, b := &this.Scores[i], &this.Scores[j]
+		if a.ReplayScore != b.ReplayScore {
+			return a.ReplayScore > b.ReplayScore
+		}
+		return a.TimestampOfReplayWindowTicks < b.TimestampOfReplayWindowTicks
+	})
+	this.NumScores = Int(len(this.Scores))
+}
+
+// The value the client writes to ScoresDbBeatMapScore.AlwaysNegativeOne
+const scoreAlwaysNegativeOne = 0xFFFFFFFF
+
+// The details of a score imported from outside the client, e.g. from a CSV
+// export of another tool.
+type ScoreParams struct {
+	Mode GameMode
+	// The hex encoded MD5 of the beatmap's .osu file
+	BeatmapMd5 string
+	PlayerName string
+	Counts     HitCounts
+	Score      int
+	MaxCombo   int
+	Perfect    bool
+	Mods       Mods
+	PlayedAt   time.Time
+	// The id of the score on the osu! website, 0 if it was not submitted
+	OnlineScoreId uint64
+	// The hash of the score's replay. When empty it is computed from the
+	// other fields like the client does.
+	ReplayMd5 string
+}
+
+// Create a scores.db entry from the score details
+// Args:
+//   params: The details of the score
+//   version: The client version the score is recorded with
+func NewScore(params ScoreParams, version Int) ScoresDbBeatMapScore {
+	score := ScoresDbBeatMapScore{
+		GameplayMode:                 Byte(params.Mode),
+		Version:                      version,
+		Md5Hash:                      NewString(params.BeatmapMd5),
+		PlayerName:                   NewString(params.PlayerName),
+		Num300:                       Short(params.Counts.Count300),
+		Num200:                       Short(params.Counts.Count100),
+		Num50:                        Short(params.Counts.Count50),
+		NumMax300:                    Short(params.Counts.CountGeki),
+		Num100:                       Short(params.Counts.CountKatu),
+		NumMiss:                      Short(params.Counts.CountMiss),
+		ReplayScore:                  Int(params.Score),
+		MaxCombo:                     Short(params.MaxCombo),
+		Mods:                         Int(params.Mods),
+		TimestampOfReplayWindowTicks: Long(TimeToTicks(params.PlayedAt)),
+		AlwaysNegativeOne:            scoreAlwaysNegativeOne,
+		OnlineScoreId:                Long(params.OnlineScoreId),
+	}
+	if params.Perfect {
+		score.IsPerfectCombo = 1
+	}
+	replayMd5 := params.ReplayMd5
+	if replayMd5 == "" {
+		header := Replay{
+			BeatmapMd5Hash: score.Md5Hash,
+			PlayerName:     score.PlayerName,
+			Num300:         score.Num300,
+			Num200:         score.Num200,
+			Num50:          score.Num50,
+			NumMax300:      score.NumMax300,
+			Num100:         score.Num100,
+			NumMiss:        score.NumMiss,
+			ReplayScore:    score.ReplayScore,
+			MaxCombo:       score.MaxCombo,
+			IsPerfectCombo: score.IsPerfectCombo,
+			Mods:           score.Mods,
+		}
+		replayMd5 = header.ComputeReplayMd5(score.Grade().String())
+	}
+	score.ReplayMd5Hash = NewString(replayMd5)
+	return score
+}
+
+// Create a scores.db entry from the header of a replay. The entry has the
+// replay's version, so it matches what the client would have recorded.
+func NewScoreFromReplay(replay *Replay) ScoresDbBeatMapScore {
+	return ScoresDbBeatMapScore{
+		GameplayMode:                 replay.GameplayMode,
+		Version:                      replay.Version,
+		Md5Hash:                      replay.BeatmapMd5Hash,
+		PlayerName:                   replay.PlayerName,
+		ReplayMd5Hash:                replay.ReplayMd5Hash,
+		Num300:                       replay.Num300,
+		Num200:                       replay.Num200,
+		Num50:                        replay.Num50,
+		NumMax300:                    replay.NumMax300,
+		Num100:                       replay.Num100,
+		NumMiss:                      replay.NumMiss,
+		ReplayScore:                  replay.ReplayScore,
+		MaxCombo:                     replay.MaxCombo,
+		IsPerfectCombo:               replay.IsPerfectCombo,
+		Mods:                         replay.Mods,
+		TimestampOfReplayWindowTicks: replay.TimestampTicks,
+		AlwaysNegativeOne:            scoreAlwaysNegativeOne,
+		OnlineScoreId:                replay.OnlineScoreId,
+	}
+}
+
+// Insert the score under the entry of its beatmap, creating the entry if the
+// beatmap has no scores yet, and keep the scores sorted. Returns false
+// without changing the db if the score is already in it, see MergeScoresDb.
+func (this *ScoresDb) InsertScore(score ScoresDbBeatMapScore) bool {
+	var beatmap *ScoresDbBeatMap
+	for i := range this.Beatmaps {
+		if this.Beatmaps[i].Md5Hash.Text == score.Md5Hash.Text {
+			beatmap = &this.Beatmaps[i]
+			break
+		}
+	}
+	if beatmap == nil {
+		this.Beatmaps = append(this.Beatmaps, ScoresDbBeatMap{Md5Hash: score.Md5Hash})
+		this.NumBeatmaps = Int(len(this.Beatmaps))
+		beatmap = &this.Beatmaps[len(this.Beatmaps)-1]
+	}
+
+	existing := newScoreSet()
+	for i := range beatmap.Scores {
+		existing.Add(&beatmap.Scores[i])
+	}
+	if existing.Contains(&score) {
+		return false
+	}
+	beatmap.Scores = append(beatmap.Scores, score)
+	beatmap.SortScores()
+	return true
+}
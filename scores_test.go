@@ -0,0 +1,178 @@
+package gosu
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMergeScoresDb(t *testing.T) {
+	score := func(points int, onlineId Long, replayMd5 string) ScoresDbBeatMapScore {
+		return ScoresDbBeatMapScore{
+			ReplayScore:   Int(points),
+			OnlineScoreId: onlineId,
+			ReplayMd5Hash: NewString(replayMd5),
+		}
+	}
+	old := &ScoresDb{
+		Version:     20171227,
+		NumBeatmaps: 1,
+		Beatmaps: []ScoresDbBeatMap{{Md5Hash: NewString("aaaa"), NumScores: 2,
+			Scores: []ScoresDbBeatMapScore{score(100, 1, "r1"), score(50, 0, "r2")}}},
+	}
+	current := &ScoresDb{
+		Version:     20180101,
+		NumBeatmaps: 2,
+		Beatmaps: []ScoresDbBeatMap{
+			{Md5Hash: NewString("bbbb"), NumScores: 1,
+				Scores: []ScoresDbBeatMapScore{score(10, 0, "r3")}},
+			{Md5Hash: NewString("aaaa"), NumScores: 6,
+				Scores: []ScoresDbBeatMapScore{score(200, 0, "r4"), score(100, 1, "other"), score(50, 0, "r2"),
+					// The submitted score r1 before it got its id
+					score(100, 0, "r1"),
+					// Local scores without a replay hash are all kept
+					score(30, 0, ""), score(20, 0, "")}},
+		},
+	}
+
+	merged := MergeScoresDb(old, current)
+	if merged.Version != 20180101 || merged.NumBeatmaps != 2 {
+		t.Fatalf("Unexpected merged db %+v", merged)
+	}
+	aaaa := merged.Beatmaps[0]
+	if aaaa.Md5Hash.Text != "aaaa" || aaaa.NumScores != 5 {
+		t.Fatalf("Expected 5 scores for aaaa, got %+v", aaaa)
+	}
+	for i, expected := range []Int{200, 100, 50, 30, 20} {
+		if aaaa.Scores[i].ReplayScore != expected {
+			t.Errorf("Score %d is %d, expected %d", i, aaaa.Scores[i].ReplayScore, expected)
+		}
+	}
+	if len(old.Beatmaps[0].Scores) != 2 || len(current.Beatmaps[1].Scores) != 6 {
+		t.Error("The merged dbs were modified")
+	}
+
+	// Merging a scores.db with itself does not duplicate anything
+	file, err := os.Open("data/scores.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scores := new(ScoresDb)
+	if err := scores.UnmarshalOsuBinary(file, Int(20171227)); err != nil {
+		t.Fatal(err)
+	}
+	merged = MergeScoresDb(scores, scores)
+	if merged.NumBeatmaps != scores.NumBeatmaps {
+		t.Errorf("Expected %d beatmaps, got %d", scores.NumBeatmaps, merged.NumBeatmaps)
+	}
+	for i := range merged.Beatmaps {
+		if merged.Beatmaps[i].NumScores != scores.Beatmaps[i].NumScores {
+			t.Errorf("Beatmap %d has %d scores, expected %d", i,
+				merged.Beatmaps[i].NumScores, scores.Beatmaps[i].NumScores)
+		}
+	}
+}
+
+func TestInsertScore(t *testing.T) {
+	file, err := os.Open("data/scores.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	scores := new(ScoresDb)
+	if err := scores.UnmarshalOsuBinary(file, Int(20171227)); err != nil {
+		t.Fatal(err)
+	}
+	existing := scores.Beatmaps[0].Scores[0]
+
+	score := NewScore(ScoreParams{
+		Mode:       ModeStandard,
+		BeatmapMd5: "0123456789abcdef0123456789abcdef",
+		PlayerName: "gosu",
+		Counts:     HitCounts{Count300: 10, Count100: 1},
+		Score:      12345,
+		MaxCombo:   11,
+		Perfect:    true,
+		Mods:       ModHidden,
+		PlayedAt:   time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+	}, scores.Version)
+	if score.EmptyString != existing.EmptyString ||
+		score.AlwaysNegativeOne != existing.AlwaysNegativeOne {
+		t.Errorf("Defaults %v/%v do not match the client's %v/%v", score.EmptyString,
+			score.AlwaysNegativeOne, existing.EmptyString, existing.AlwaysNegativeOne)
+	}
+	if err := ValidateAny(&score); err != nil {
+		t.Error(err)
+	}
+
+	numBeatmaps := scores.NumBeatmaps
+	if !scores.InsertScore(score) {
+		t.Fatal("Expected the score to be inserted")
+	}
+	if scores.InsertScore(score) {
+		t.Error("Expected the duplicate score to be ignored")
+	}
+	if scores.NumBeatmaps != numBeatmaps+1 || scores.Beatmaps[numBeatmaps].NumScores != 1 {
+		t.Errorf("Expected a new beatmap entry with 1 score")
+	}
+
+	// Adding a score to an existing beatmap keeps its scores sorted
+	existing.ReplayScore++
+	existing.ReplayMd5Hash = NewString("fedcba9876543210fedcba9876543210")
+	if !scores.InsertScore(existing) {
+		t.Fatal("Expected the score to be inserted")
+	}
+	if scores.Beatmaps[0].Scores[0] != existing {
+		t.Errorf("Expected the higher score to be first")
+	}
+	if int(scores.Beatmaps[0].NumScores) != len(scores.Beatmaps[0].Scores) {
+		t.Errorf("NumScores was not updated")
+	}
+
+	var buf bytes.Buffer
+	if err := MarshalValidated(scores, &buf, scores.Version); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewScoreFromReplay(t *testing.T) {
+	osu, err := ReadOsuFile(testOsuFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder := ReplayBuilder{
+		Beatmap:    osu,
+		BeatmapMd5: "0123456789abcdef0123456789abcdef",
+		PlayerName: "gosu",
+		Mods:       ModHidden,
+		Score:      1000,
+		Timestamp:  time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC),
+		Version:    20171227,
+	}
+	replay, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	score := NewScoreFromReplay(replay)
+	if mismatch := replayMismatch(replay, &score); mismatch != "" {
+		t.Error(mismatch)
+	}
+
+	// A score built from the same values has the same replay hash
+	params := NewScore(ScoreParams{
+		Mode:       ModeStandard,
+		BeatmapMd5: builder.BeatmapMd5,
+		PlayerName: builder.PlayerName,
+		Counts:     replay.HitCounts(),
+		Score:      builder.Score,
+		MaxCombo:   int(replay.MaxCombo),
+		Perfect:    replay.IsPerfectCombo != 0,
+		Mods:       builder.Mods,
+		PlayedAt:   builder.Timestamp,
+	}, builder.Version)
+	if params != score {
+		t.Errorf("Expected %+v, got %+v", score, params)
+	}
+}
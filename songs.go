@@ -0,0 +1,253 @@
+package gosu
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Helpers for rebuilding the osu!.db beatmap entries from the .osu files found
+// in a Songs directory.
+
+// A .osu file which could not be turned into a BeatMap entry
+type ScanError struct {
+	Path string
+	Err  error
+}
+
+func (this ScanError) Error() string {
+	return fmt.Sprintf("%s: %v", this.Path, this.Err)
+}
+
+// Walk the given Songs directory and build an OsuDb containing a BeatMap entry
+// for every .osu file found. Files which fail to parse are skipped and
+// returned in the list of ScanErrors. The returned error is only set if the
+// directory itself could not be walked.
+// Args:
+//   songsDir: The path to the osu! Songs directory
+//   version: The osu! version for which the DB should be built
+func ScanSongsFolder(songsDir string, version Int) (*OsuDb, []ScanError, error) {
+	paths, err := findOsuFiles(songsDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var scanErrors []ScanError
+	beatmaps := make([]BeatMap, 0, len(paths))
+	for _, relPath := range paths {
+		beatmap, err := LoadBeatMap(songsDir, relPath, version)
+		if err != nil {
+			scanErrors = append(scanErrors, ScanError{relPath, err})
+			continue
+		}
+		beatmaps = append(beatmaps, beatmap)
+	}
+
+	db := NewOsuDb(version, beatmaps)
+	return db, scanErrors, nil
+}
+
+// Create a new OsuDb holding the given beatmaps. The folder count is derived
+// from the distinct folders referenced by the beatmaps.
+func NewOsuDb(version Int, beatmaps []BeatMap) *OsuDb {
+	db := &OsuDb{
+		Version:         version,
+		AccountUnlocked: 1,
+		PlayerName:      NewString(""),
+		NumBeatmaps:     Int(len(beatmaps)),
+		Beatmaps:        beatmaps,
+	}
+	db.FolderCount = Int(countFolders(beatmaps))
+	return db
+}
+
+func countFolders(beatmaps []BeatMap) int {
+	folders := make(map[string]bool)
+	for i := range beatmaps {
+		folders[strings.ToLower(beatmaps[i].RelativeFolderName.Text)] = true
+	}
+	return len(folders)
+}
+
+// Returns the path of every .osu file under the songsDir, relative to the
+// songsDir and in lexical order.
+func findOsuFiles(songsDir string) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(songsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".osu") {
+			return nil
+		}
+		relPath, err := filepath.Rel(songsDir, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, relPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Read, hash and parse a single .osu file and build its BeatMap entry.
+// Args:
+//   songsDir: The path to the osu! Songs directory
+//   relPath: The path of the .osu file relative to the songsDir
+//   version: The osu! version for which the BeatMap should be built
+func LoadBeatMap(songsDir, relPath string, version Int) (BeatMap, error) {
+	path := filepath.Join(songsDir, relPath)
+	info, err := os.Stat(path)
+	if err != nil {
+		return BeatMap{}, err
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return BeatMap{}, err
+	}
+	osu, err := ParseOsuFile(bytes.NewReader(contents))
+	if err != nil {
+		return BeatMap{}, err
+	}
+
+	hash := md5.Sum(contents)
+	return NewBeatMap(osu, BeatMapFileInfo{
+		Md5:        hex.EncodeToString(hash[:]),
+		FolderName: toOsuPath(filepath.Dir(relPath)),
+		FileName:   filepath.Base(relPath),
+		ModTime:    info.ModTime(),
+	}, version)
+}
+
+// The on-disk information about a .osu file which is not contained in the
+// file itself.
+type BeatMapFileInfo struct {
+	// The hex encoded md5 hash of the .osu file
+	Md5 string
+	// The folder containing the .osu file, relative to the Songs directory
+	FolderName string
+	// The name of the .osu file
+	FileName string
+	// The last modification time of the .osu file
+	ModTime time.Time
+}
+
+// Build the osu!.db BeatMap entry for a parsed .osu file.
+// Fields which are only known to the client (ranked status, grades, star
+// ratings, online offsets) are left at the values the client uses for a
+// freshly imported beatmap, so that it refreshes them itself.
+func NewBeatMap(osu *OsuFile, info BeatMapFileInfo, version Int) (BeatMap, error) {
+	b := BeatMap{
+		ArtistName:         NewString(osu.Artist),
+		ArtistNameUnicode:  NewString(osu.ArtistUnicode),
+		SongTitle:          NewString(osu.Title),
+		SongTitleUnicode:   NewString(osu.TitleUnicode),
+		CreatorName:        NewString(osu.Creator),
+		Difficulty:         NewString(osu.Version),
+		AudioFileName:      NewString(osu.AudioFilename),
+		Md5:                NewString(info.Md5),
+		OsuFileName:        NewString(info.FileName),
+		LastModTimeTicks:   Long(TimeToTicks(info.ModTime)),
+		ApproachRate:       Single(osu.ApproachRate),
+		CircleSize:         Single(osu.CircleSize),
+		HPDrainRate:        Single(osu.HPDrainRate),
+		OverallDifficulty:  Single(osu.OverallDifficulty),
+		SliderVelocity:     Double(osu.SliderMultiplier),
+		DrainTimeSecs:      Int(osu.DrainTimeSecs()),
+		TotalTimeMsec:      Int(osu.TotalTimeMsec()),
+		AudioPreviewMsec:   Int(uint32(int32(osu.PreviewTime))),
+		BeatmapID:          Int(uint32(int32(osu.BeatmapID))),
+		BeatmapSetID:       Int(uint32(int32(osu.BeatmapSetID))),
+		StackLeniency:      Single(osu.StackLeniency),
+		OsuGameplayMode:    Byte(osu.Mode),
+		SongSource:         NewString(osu.Source),
+		SongTags:           NewString(osu.Tags),
+		TitleFont:          NewString(""),
+		RelativeFolderName: NewString(info.FolderName),
+		GradeOsuStandard:   GradeNone,
+		GradeTaiko:         GradeNone,
+		GradeCTB:           GradeNone,
+		GradeMania:         GradeNone,
+		// Despite the name this flag is set when the beatmap is NOT played
+		IsPlayed: 1,
+	}
+
+	if version <= Int(20140609) {
+		// Older versions of the DB stored the difficulty values as bytes
+		b.ApproachRateByte = Byte(math.Round(osu.ApproachRate))
+		b.CircleSizeByte = Byte(math.Round(osu.CircleSize))
+		b.HPDrainRateByte = Byte(math.Round(osu.HPDrainRate))
+		b.OverallDifficultyByte = Byte(math.Round(osu.OverallDifficulty))
+	}
+	if version >= Int(20140609) {
+		// The star ratings are left empty so that the client computes them.
+		b.OsuStandardStarRating = []IntDoublePair{}
+		b.TaikoStarRating = []IntDoublePair{}
+		b.CTBStarRating = []IntDoublePair{}
+		b.ManiaStarRating = []IntDoublePair{}
+	}
+
+	for i := range osu.HitObjects {
+		h := &osu.HitObjects[i]
+		switch {
+		case h.IsSlider():
+			b.NumOfSliders++
+		case h.IsSpinner():
+			b.NumOfSpinners++
+		default:
+			// Circles and mania hold notes are both counted as hit circles
+			b.NumHitCircles++
+		}
+	}
+
+	b.TimingPoints = osu.DbTimingPoints()
+	b.NumTimingPoints = Int(len(b.TimingPoints))
+
+	if err := b.UpdateSize(version); err != nil {
+		return BeatMap{}, err
+	}
+	return b, nil
+}
+
+// Recompute SizeOfBeatmapBytes, which must be kept up to date whenever a
+// field of the beatmap is changed.
+func (this *BeatMap) UpdateSize(version Int) error {
+	size, err := sizeOfBeatMap(this, version)
+	if err != nil {
+		return err
+	}
+	this.SizeOfBeatmapBytes = Int(size)
+	return nil
+}
+
+// Returns the number of bytes the beatmap takes up in osu!.db, not including
+// the SizeOfBeatmapBytes field itself.
+func sizeOfBeatMap(b *BeatMap, version Int) (int, error) {
+	var buf bytes.Buffer
+	if err := b.MarshalOsuBinary(&buf, version); err != nil {
+		return 0, err
+	}
+	return buf.Len() - 4, nil
+}
+
+// Convert a relative file path to the windows style path used within the DBs
+func toOsuPath(path string) string {
+	return strings.Replace(filepath.ToSlash(path), "/", "\\", -1)
+}
+
+// Convert a windows style path used within the DBs to a local file path
+func fromOsuPath(path string) string {
+	return filepath.FromSlash(strings.Replace(path, "\\", "/", -1))
+}
@@ -0,0 +1,112 @@
+package gosu
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/d4l3k/messagediff"
+)
+
+func TestScanSongsFolder(t *testing.T) {
+	db, scanErrors, err := ScanSongsFolder("data/Songs", Int(20171227))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scanErrors) != 0 {
+		t.Errorf("Unexpected scan errors %v", scanErrors)
+	}
+	if db.NumBeatmaps != 1 || len(db.Beatmaps) != 1 || db.FolderCount != 1 {
+		t.Fatalf("Expected exactly one beatmap, got %d", len(db.Beatmaps))
+	}
+
+	b := db.Beatmaps[0]
+	testcases := []struct {
+		Name string
+		Got  interface{}
+		Want interface{}
+	}{
+		{"SongTitle", b.SongTitle.Text, "Test Song"},
+		{"Difficulty", b.Difficulty.Text, "Normal"},
+		{"RelativeFolderName", b.RelativeFolderName.Text, "1 gosu - Test Song"},
+		{"NumHitCircles", b.NumHitCircles, Short(11)},
+		{"NumOfSliders", b.NumOfSliders, Short(2)},
+		{"NumOfSpinners", b.NumOfSpinners, Short(1)},
+		{"TotalTimeMsec", b.TotalTimeMsec, Int(13500)},
+		{"DrainTimeSecs", b.DrainTimeSecs, Int(10)},
+		{"AudioPreviewMsec", b.AudioPreviewMsec, Int(5000)},
+		{"ApproachRate", b.ApproachRate, Single(7)},
+		{"NumTimingPoints", b.NumTimingPoints, Int(3)},
+		{"BeatmapSetID", b.BeatmapSetID, Int(0xffffffff)},
+	}
+	for _, testcase := range testcases {
+		if testcase.Got != testcase.Want {
+			t.Errorf("%s: got %v, want %v", testcase.Name, testcase.Got, testcase.Want)
+		}
+	}
+
+	// The rebuilt DB must survive a round trip through the codec
+	var buf bytes.Buffer
+	if err := db.MarshalOsuBinary(&buf, db.Version); err != nil {
+		t.Fatal(err)
+	}
+	var final OsuDb
+	if err := final.UnmarshalOsuBinary(&buf, db.Version); err != nil {
+		t.Fatal(err)
+	}
+	if diff, equal := messagediff.PrettyDiff(*db, final); !equal {
+		t.Errorf("Marshal/Unmarshal failed.\n%s", diff)
+	}
+}
+
+func TestScanSongsFolderReportsBadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosu-songs-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.Mkdir(filepath.Join(dir, "broken"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	badPath := filepath.Join(dir, "broken", "bad.osu")
+	if err := ioutil.WriteFile(badPath, []byte("not a beatmap"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, scanErrors, err := ScanSongsFolder(dir, Int(20171227))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db.Beatmaps) != 0 {
+		t.Errorf("Expected no beatmaps, got %d", len(db.Beatmaps))
+	}
+	if len(scanErrors) != 1 || scanErrors[0].Path != filepath.Join("broken", "bad.osu") {
+		t.Errorf("Expected one scan error for bad.osu, got %v", scanErrors)
+	}
+}
+
+func TestSizeOfBeatMap(t *testing.T) {
+	file, err := os.Open("data/osu!.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var db OsuDb
+	if err := db.UnmarshalOsuBinary(file, Int(20171227)); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		size, err := sizeOfBeatMap(&db.Beatmaps[i], db.Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if Int(size) != db.Beatmaps[i].SizeOfBeatmapBytes {
+			t.Errorf("Beatmap %d: got size %d, want %d",
+				i, size, db.Beatmaps[i].SizeOfBeatmapBytes)
+		}
+	}
+}
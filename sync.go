@@ -0,0 +1,179 @@
+package gosu
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The drift between the beatmaps recorded in an OsuDb and the .osu files
+// found in a Songs directory.
+type SongsDiff struct {
+	// .osu files which are on disk but not in the DB
+	Added []BeatMap
+	// Entries whose .osu file has been modified since the DB was written. The
+	// BeatMaps hold the updated entries.
+	Changed []BeatMap
+	// Entries whose .osu file no longer exists on disk
+	Removed []BeatMap
+	// .osu files which were new or changed but could not be parsed
+	Errors []ScanError
+}
+
+// Returns true if the DB is in sync with the Songs directory
+func (this *SongsDiff) Empty() bool {
+	return len(this.Added) == 0 && len(this.Changed) == 0 && len(this.Removed) == 0
+}
+
+// Compare the beatmaps in the db against the .osu files in the Songs
+// directory. Only files whose modification time differs from the
+// LastModTimeTicks recorded in the DB are re-read; of those, files with the
+// same MD5 only have their timestamp refreshed while files with a different
+// MD5 are fully re-parsed.
+// Args:
+//   db: The OsuDb to compare against
+//   songsDir: The path to the osu! Songs directory
+func DiffSongsFolder(db *OsuDb, songsDir string) (*SongsDiff, error) {
+	paths, err := findOsuFiles(songsDir)
+	if err != nil {
+		return nil, err
+	}
+	onDisk := make(map[string]string, len(paths))
+	for _, relPath := range paths {
+		onDisk[strings.ToLower(toOsuPath(relPath))] = relPath
+	}
+
+	diff := &SongsDiff{}
+	inDb := make(map[string]bool, len(db.Beatmaps))
+	for i := range db.Beatmaps {
+		old := &db.Beatmaps[i]
+		key := beatMapKey(old)
+		inDb[key] = true
+
+		relPath, ok := onDisk[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, *old)
+			continue
+		}
+
+		updated, changed, err := refreshBeatMap(old, songsDir, relPath, db.Version)
+		if err != nil {
+			diff.Errors = append(diff.Errors, ScanError{relPath, err})
+			continue
+		}
+		if changed {
+			diff.Changed = append(diff.Changed, updated)
+		}
+	}
+
+	for _, relPath := range paths {
+		if inDb[strings.ToLower(toOsuPath(relPath))] {
+			continue
+		}
+		beatmap, err := LoadBeatMap(songsDir, relPath, db.Version)
+		if err != nil {
+			diff.Errors = append(diff.Errors, ScanError{relPath, err})
+			continue
+		}
+		diff.Added = append(diff.Added, beatmap)
+	}
+	return diff, nil
+}
+
+// Apply the diff to the db and return the updated OsuDb. The given db is left
+// untouched. Existing entries keep their order, added entries are appended.
+func (this *SongsDiff) Apply(db *OsuDb) *OsuDb {
+	changed := make(map[string]*BeatMap, len(this.Changed))
+	for i := range this.Changed {
+		changed[beatMapKey(&this.Changed[i])] = &this.Changed[i]
+	}
+	removed := make(map[string]bool, len(this.Removed))
+	for i := range this.Removed {
+		removed[beatMapKey(&this.Removed[i])] = true
+	}
+
+	beatmaps := make([]BeatMap, 0, len(db.Beatmaps)+len(this.Added))
+	for i := range db.Beatmaps {
+		key := beatMapKey(&db.Beatmaps[i])
+		if removed[key] {
+			continue
+		}
+		if updated, ok := changed[key]; ok {
+			beatmaps = append(beatmaps, *updated)
+			continue
+		}
+		beatmaps = append(beatmaps, db.Beatmaps[i])
+	}
+	beatmaps = append(beatmaps, this.Added...)
+
+	updated := *db
+	updated.Beatmaps = beatmaps
+	updated.NumBeatmaps = Int(len(beatmaps))
+	updated.FolderCount = Int(countFolders(beatmaps))
+	return &updated
+}
+
+// Check whether the .osu file backing the old entry has changed and if so
+// return the updated entry.
+func refreshBeatMap(old *BeatMap, songsDir, relPath string, version Int) (BeatMap, bool, error) {
+	path := filepath.Join(songsDir, relPath)
+	info, err := os.Stat(path)
+	if err != nil {
+		return BeatMap{}, false, err
+	}
+	modTicks := Long(TimeToTicks(info.ModTime()))
+	if modTicks == old.LastModTimeTicks {
+		return BeatMap{}, false, nil
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return BeatMap{}, false, err
+	}
+	hash := md5.Sum(contents)
+	md5Hex := hex.EncodeToString(hash[:])
+	if md5Hex == old.Md5.Text {
+		// Only the timestamp was touched
+		updated := *old
+		updated.LastModTimeTicks = modTicks
+		return updated, true, nil
+	}
+
+	osu, err := ParseOsuFile(bytes.NewReader(contents))
+	if err != nil {
+		return BeatMap{}, false, err
+	}
+	updated, err := NewBeatMap(osu, BeatMapFileInfo{
+		Md5:        md5Hex,
+		FolderName: old.RelativeFolderName.Text,
+		FileName:   old.OsuFileName.Text,
+		ModTime:    info.ModTime(),
+	}, version)
+	if err != nil {
+		return BeatMap{}, false, err
+	}
+
+	// Keep the per-beatmap settings the player chose in the client
+	updated.LocalBeatmapOffset = old.LocalBeatmapOffset
+	updated.IgnoreBeatmapSound = old.IgnoreBeatmapSound
+	updated.IgnoreBeatmapSkin = old.IgnoreBeatmapSkin
+	updated.DisableStoryboard = old.DisableStoryboard
+	updated.DisableVideo = old.DisableVideo
+	updated.VisualOverride = old.VisualOverride
+	updated.ManiaScrollSpeed = old.ManiaScrollSpeed
+	return updated, true, nil
+}
+
+// Returns the case-insensitive folder\file key identifying the .osu file of
+// a beatmap entry.
+func beatMapKey(b *BeatMap) string {
+	key := b.OsuFileName.Text
+	if folder := b.RelativeFolderName.Text; folder != "" && folder != "." {
+		key = folder + "\\" + key
+	}
+	return strings.ToLower(key)
+}
@@ -0,0 +1,98 @@
+package gosu
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiffSongsFolder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gosu-songs-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fixture, err := ioutil.ReadFile(
+		"data/Songs/1 gosu - Test Song/gosu - Test Song (Stymphalian) [Normal].osu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(folder, name string, contents []byte) string {
+		if err := os.MkdirAll(filepath.Join(dir, folder), 0755); err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, folder, name)
+		if err := ioutil.WriteFile(path, contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("1 kept", "kept.osu", fixture)
+	changedPath := write("2 changed", "changed.osu", fixture)
+	removedPath := write("3 removed", "removed.osu", fixture)
+
+	db, _, err := ScanSongsFolder(dir, Int(20171227))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := DiffSongsFolder(db, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Empty() {
+		t.Fatalf("Expected no drift right after a scan, got %+v", diff)
+	}
+
+	// Drift the songs folder away from the DB
+	changed := bytes.Replace(fixture, []byte("Version:Normal"), []byte("Version:Hard"), 1)
+	write("2 changed", "changed.osu", changed)
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(changedPath, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(removedPath); err != nil {
+		t.Fatal(err)
+	}
+	write("4 added", "added.osu", fixture)
+
+	diff, err = DiffSongsFolder(db, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added) != 1 || diff.Added[0].OsuFileName.Text != "added.osu" {
+		t.Errorf("Expected added.osu to be added, got %v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].OsuFileName.Text != "removed.osu" {
+		t.Errorf("Expected removed.osu to be removed, got %v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Difficulty.Text != "Hard" {
+		t.Errorf("Expected changed.osu to be changed, got %v", diff.Changed)
+	}
+
+	updated := diff.Apply(db)
+	rescanned, _, err := ScanSongsFolder(dir, Int(20171227))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.NumBeatmaps != 3 || updated.FolderCount != rescanned.FolderCount {
+		t.Errorf("Expected 3 beatmaps in %d folders, got %d in %d",
+			rescanned.FolderCount, updated.NumBeatmaps, updated.FolderCount)
+	}
+	for i := range rescanned.Beatmaps {
+		want := rescanned.Beatmaps[i]
+		found := false
+		for j := range updated.Beatmaps {
+			if updated.Beatmaps[j].Md5 == want.Md5 &&
+				updated.Beatmaps[j].OsuFileName == want.OsuFileName {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Applied diff is missing %s", want.OsuFileName.Text)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package gosu
+
+import (
+	"math"
+	"sort"
+)
+
+// Accessors for the raw TimingPoint fields stored in osu!.db. The BPM field
+// actually holds the beat length in msec for uninherited points and a
+// negative slider velocity multiplier (-100/sv) for inherited points, and the
+// IsInherited byte is set for the points which are NOT inherited.
+
+// Returns true if this is an uninherited (red line) timing point
+func (this *TimingPoint) Uninherited() bool {
+	return this.IsInherited != 0
+}
+
+// Returns true if this is an inherited (green line) timing point which only
+// changes the slider velocity.
+func (this *TimingPoint) Inherited() bool {
+	return !this.Uninherited()
+}
+
+// Returns the length of a beat in msec, or 0 for inherited points
+func (this *TimingPoint) BeatLength() float64 {
+	if this.Inherited() {
+		return 0
+	}
+	return float64(this.BPM)
+}
+
+// Returns the beats per minute, or 0 for inherited points
+func (this *TimingPoint) BeatsPerMinute() float64 {
+	beatLength := this.BeatLength()
+	if beatLength <= 0 {
+		return 0
+	}
+	return 60000 / beatLength
+}
+
+// Returns the slider velocity multiplier set by this point. Uninherited
+// points reset the multiplier to 1.
+func (this *TimingPoint) SliderVelocityMultiplier() float64 {
+	if this.Uninherited() || this.BPM >= 0 {
+		return 1
+	}
+	return -100 / float64(this.BPM)
+}
+
+// Answers timing questions ("what is the BPM at time t") for the timing
+// points of a beatmap.
+type TimingMap struct {
+	// The number of beats in a measure. osu!.db does not store the meter of
+	// the timing points so this defaults to 4.
+	BeatsPerMeasure int
+
+	points []TimingPoint
+	// Indices into points of the uninherited points
+	red     []int
+	endMsec float64
+}
+
+// Create a TimingMap for the given timing points.
+// Args:
+//   points: The timing points of the beatmap
+//   endMsec: The time at which the beatmap ends, used to weigh the
+//     duration of the last timing section.
+func NewTimingMap(points []TimingPoint, endMsec float64) *TimingMap {
+	sorted := make([]TimingPoint, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].OffsetMsec < sorted[j].OffsetMsec
+	})
+
+	tm := &TimingMap{BeatsPerMeasure: 4, points: sorted, endMsec: endMsec}
+	for i := range sorted {
+		if sorted[i].Uninherited() && sorted[i].BPM > 0 {
+			tm.red = append(tm.red, i)
+		}
+	}
+	return tm
+}
+
+// Returns the TimingMap of the beatmap's timing points
+func (this *BeatMap) TimingMap() *TimingMap {
+	return NewTimingMap(this.TimingPoints, float64(this.TotalTimeMsec))
+}
+
+// Returns the TimingMap of the parsed .osu file's timing points
+func (this *OsuFile) TimingMap() *TimingMap {
+	tm := NewTimingMap(this.DbTimingPoints(), 0)
+	tm.endMsec = float64(this.totalTimeMsec(tm))
+	return tm
+}
+
+// Returns the index into red of the uninherited point active at the time. Times
+// before the first uninherited point use the first uninherited point.
+func (this *TimingMap) redAt(timeMsec float64) int {
+	i := sort.Search(len(this.red), func(i int) bool {
+		return float64(this.points[this.red[i]].OffsetMsec) > timeMsec
+	})
+	if i == 0 {
+		return 0
+	}
+	return i - 1
+}
+
+// Returns the length of a beat in msec at the given time
+func (this *TimingMap) BeatLengthAt(timeMsec float64) float64 {
+	if len(this.red) == 0 {
+		return 0
+	}
+	return this.points[this.red[this.redAt(timeMsec)]].BeatLength()
+}
+
+// The beat length the client assumes for a beatmap without uninherited
+// points, 120 BPM
+const defaultBeatLengthMsec = 500
+
+// Returns the length of a beat in msec at the given time, or the default
+// beat length if there are no uninherited points. Used to time hit objects.
+func (this *TimingMap) beatLengthOrDefault(timeMsec float64) float64 {
+	if len(this.red) == 0 {
+		return defaultBeatLengthMsec
+	}
+	return this.BeatLengthAt(timeMsec)
+}
+
+// Returns the BPM at the given time
+func (this *TimingMap) BPMAt(timeMsec float64) float64 {
+	if len(this.red) == 0 {
+		return 0
+	}
+	return this.points[this.red[this.redAt(timeMsec)]].BeatsPerMinute()
+}
+
+// Returns the slider velocity multiplier at the given time. Times before the
+// first timing point use the first timing point.
+func (this *TimingMap) SliderVelocityAt(timeMsec float64) float64 {
+	sv := 1.0
+	for i := range this.points {
+		if i > 0 && float64(this.points[i].OffsetMsec) > timeMsec {
+			break
+		}
+		sv = this.points[i].SliderVelocityMultiplier()
+	}
+	return sv
+}
+
+// Returns the measure and the beat within that measure at the given time.
+// Every uninherited point starts a new measure. Times before the first
+// uninherited point return negative measures.
+func (this *TimingMap) BeatAt(timeMsec float64) (measure int, beat float64) {
+	if len(this.red) == 0 {
+		return 0, 0
+	}
+	meter := float64(this.BeatsPerMeasure)
+	current := this.redAt(timeMsec)
+	for i := 0; i < current; i++ {
+		from := this.points[this.red[i]]
+		to := this.points[this.red[i+1]]
+		beats := float64(to.OffsetMsec-from.OffsetMsec) / from.BeatLength()
+		measure += int(math.Ceil(beats / meter))
+	}
+
+	point := this.points[this.red[current]]
+	beats := (timeMsec - float64(point.OffsetMsec)) / point.BeatLength()
+	measures := math.Floor(beats / meter)
+	return measure + int(measures), beats - measures*meter
+}
+
+// Returns the BPM of the first uninherited point
+func (this *TimingMap) MainBPM() float64 {
+	if len(this.red) == 0 {
+		return 0
+	}
+	return this.points[this.red[0]].BeatsPerMinute()
+}
+
+// Returns the lowest BPM of all uninherited points
+func (this *TimingMap) MinBPM() float64 {
+	min := 0.0
+	for i, index := range this.red {
+		if bpm := this.points[index].BeatsPerMinute(); i == 0 || bpm < min {
+			min = bpm
+		}
+	}
+	return min
+}
+
+// Returns the highest BPM of all uninherited points
+func (this *TimingMap) MaxBPM() float64 {
+	max := 0.0
+	for _, index := range this.red {
+		if bpm := this.points[index].BeatsPerMinute(); bpm > max {
+			max = bpm
+		}
+	}
+	return max
+}
+
+// Returns the BPM which is active for the longest time between the first
+// uninherited point and the end of the beatmap.
+func (this *TimingMap) DominantBPM() float64 {
+	if len(this.red) == 0 {
+		return 0
+	}
+	durations := this.bpmDurations(float64(this.points[this.red[0]].OffsetMsec), this.endMsec)
+
+	dominant := this.MainBPM()
+	longest := 0.0
+	for bpm, duration := range durations {
+		if duration > longest || (duration == longest && bpm < dominant) {
+			dominant = bpm
+			longest = duration
+		}
+	}
+	return dominant
+}
+
+// Returns how long (in msec) each BPM is active between the two times. The
+// first uninherited point counts from fromMsec even if it comes later.
+func (this *TimingMap) bpmDurations(fromMsec, toMsec float64) map[float64]float64 {
+	durations := make(map[float64]float64)
+	for i, index := range this.red {
+		from := fromMsec
+		if i > 0 {
+			from = math.Max(from, float64(this.points[index].OffsetMsec))
+		}
+		to := toMsec
+		if i+1 < len(this.red) {
+			to = math.Min(to, float64(this.points[this.red[i+1]].OffsetMsec))
+		}
+		if to > from {
+			durations[this.points[index].BeatsPerMinute()] += to - from
+		}
+	}
+	return durations
+}
@@ -0,0 +1,74 @@
+package gosu
+
+import (
+	"testing"
+)
+
+func TestTimingMap(t *testing.T) {
+	osu, err := ReadOsuFile(testOsuFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	beatmap, err := NewBeatMap(osu, BeatMapFileInfo{}, Int(20171227))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tm := beatmap.TimingMap()
+
+	if !beatmap.TimingPoints[0].Uninherited() || !beatmap.TimingPoints[1].Inherited() {
+		t.Errorf("Inherited flags are wrong: %+v", beatmap.TimingPoints)
+	}
+
+	testcases := []struct {
+		TimeMsec float64
+		BPM      float64
+		SV       float64
+		Measure  int
+		Beat     float64
+	}{
+		{0, 120, 1, -1, 2},
+		{1000, 120, 1, 0, 0},
+		{3250, 120, 1, 1, 0.5},
+		{6000, 120, 0.5, 2, 2},
+		{13250, 240, 1, 6, 1},
+	}
+	for _, testcase := range testcases {
+		if bpm := tm.BPMAt(testcase.TimeMsec); bpm != testcase.BPM {
+			t.Errorf("BPMAt(%v): got %v, want %v", testcase.TimeMsec, bpm, testcase.BPM)
+		}
+		if sv := tm.SliderVelocityAt(testcase.TimeMsec); sv != testcase.SV {
+			t.Errorf("SliderVelocityAt(%v): got %v, want %v", testcase.TimeMsec, sv, testcase.SV)
+		}
+		measure, beat := tm.BeatAt(testcase.TimeMsec)
+		if measure != testcase.Measure || beat != testcase.Beat {
+			t.Errorf("BeatAt(%v): got %d/%v, want %d/%v", testcase.TimeMsec,
+				measure, beat, testcase.Measure, testcase.Beat)
+		}
+	}
+
+	if tm.MainBPM() != 120 || tm.MinBPM() != 120 || tm.MaxBPM() != 240 {
+		t.Errorf("Got main/min/max BPM %v/%v/%v", tm.MainBPM(), tm.MinBPM(), tm.MaxBPM())
+	}
+	if tm.DominantBPM() != 120 {
+		t.Errorf("Got dominant BPM %v, want 120", tm.DominantBPM())
+	}
+}
+
+func TestEndTimeWithoutUninheritedPoint(t *testing.T) {
+	// Only an inherited point doubling the slider velocity, after the slider
+	osu := &OsuFile{
+		SliderMultiplier: 1,
+		TimingPoints:     []OsuFileTimingPoint{{OffsetMsec: 2000, BeatLength: -50}},
+		HitObjects: []HitObject{
+			{TimeMsec: 500, Type: HitObjectCircle},
+			{TimeMsec: 1000, Type: HitObjectSlider, Slides: 1, Length: 100},
+		},
+	}
+	// The first point applies and a beat takes 500 msec: 100 / (100 * 2) * 500
+	if end := osu.EndTimeOf(&osu.HitObjects[1]); end != 1250 {
+		t.Errorf("Got slider end time %d, want 1250", end)
+	}
+	if total := osu.TotalTimeMsec(); total != 1250 {
+		t.Errorf("Got total time %d, want 1250", total)
+	}
+}
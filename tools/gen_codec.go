@@ -1,20 +1,36 @@
 // The following directive is necessary to make the package coherent:
 
+//go:build ignore
 // +build ignore
 
-// This program generates contributors.go. It can be invoked by running
+// This program generates codec.auto.go. It can be invoked by running
 // go generate
+//
+// It parses the package source for types marked with a directive comment:
+//   //gosu:codec         the fields are encoded one by one by UnmarshalAny
+//                        and MarshalAny
+//   //gosu:codec binary  the value is encoded as is by encoding/binary
+//
+// The generator fails if a field of a marked struct has a type without a
+// codec, so that a new format type can not silently be left out.
 package main
 
 import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"log"
 	"os"
-	"reflect"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
+)
 
-	"github.com/Stymphalian/gosu"
+const (
+	outputFile = "codec.auto.go"
+	directive  = "//gosu:codec"
 )
 
 type Thing struct {
@@ -23,43 +39,178 @@ type Thing struct {
 	Commons   []string
 }
 
+// A type marked for code generation
+type markedType struct {
+	Name   string
+	Binary bool
+	Spec   *ast.TypeSpec
+	Pos    token.Position
+}
+
 func main() {
-	f, err := os.Create("codec.auto.go")
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(info os.FileInfo) bool {
+		return info.Name() != outputFile && !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		log.Fatal(err)
+	}
+	pkg, ok := pkgs["gosu"]
+	if !ok {
+		log.Fatal("Package gosu not found in the current directory")
+	}
+
+	var fileNames []string
+	for name := range pkg.Files {
+		fileNames = append(fileNames, name)
+	}
+	sort.Strings(fileNames)
+
+	var marked []markedType
+	// The types which have a codec, either generated or written by hand
+	hasCodec := make(map[string]bool)
+	for _, name := range fileNames {
+		file := pkg.Files[name]
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *ast.GenDecl:
+				if decl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					spec := spec.(*ast.TypeSpec)
+					doc := spec.Doc
+					if doc == nil && len(decl.Specs) == 1 {
+						doc = decl.Doc
+					}
+					binary, ok := parseDirective(doc)
+					if !ok {
+						continue
+					}
+					marked = append(marked, markedType{spec.Name.Name, binary, spec,
+						fset.Position(spec.Pos())})
+					hasCodec[spec.Name.Name] = true
+				}
+			case *ast.FuncDecl:
+				if decl.Recv != nil && decl.Name.Name == "UnmarshalOsuBinary" {
+					if star, ok := decl.Recv.List[0].Type.(*ast.StarExpr); ok {
+						if ident, ok := star.X.(*ast.Ident); ok {
+							hasCodec[ident.Name] = true
+						}
+					}
+				}
+			}
+		}
+	}
+
+	thing := Thing{Timestamp: time.Now()}
+	var errs []string
+	for _, t := range marked {
+		if t.Binary {
+			errs = append(errs, checkBinary(t)...)
+			thing.Commons = append(thing.Commons, t.Name)
+		} else {
+			errs = append(errs, checkCodec(t, hasCodec, fset)...)
+			thing.Codecs = append(thing.Codecs, t.Name)
+		}
+	}
+	if len(errs) > 0 {
+		log.Fatal("Can not generate the codecs:\n" + strings.Join(errs, "\n"))
+	}
+
+	f, err := os.Create(outputFile)
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer f.Close()
+	if err := packageTemplate.Execute(f, thing); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Returns whether the doc comment holds the directive and whether it asks
+// for a binary codec.
+func parseDirective(doc *ast.CommentGroup) (binary bool, ok bool) {
+	if doc == nil {
+		return false, false
+	}
+	for _, comment := range doc.List {
+		if !strings.HasPrefix(comment.Text, directive) {
+			continue
+		}
+		switch strings.TrimSpace(strings.TrimPrefix(comment.Text, directive)) {
+		case "":
+			return false, true
+		case "binary":
+			return true, true
+		}
+		log.Fatalf("Unknown directive %q", comment.Text)
+	}
+	return false, false
+}
 
-	packageName := "gosu"
-	thing := Thing{
-		time.Now(),
-		[]string{
-			strings.TrimLeft(reflect.TypeOf(gosu.OsuDb{}).Name(), packageName),
-			strings.TrimLeft(reflect.TypeOf(gosu.IntDoublePair{}).Name(), packageName),
-			strings.TrimLeft(reflect.TypeOf(gosu.TimingPoint{}).Name(), packageName),
-			strings.TrimLeft(reflect.TypeOf(gosu.BeatMap{}).Name(), packageName),
-			strings.TrimLeft(reflect.TypeOf(gosu.CollectionDb{}).Name(), packageName),
-			strings.TrimLeft(reflect.TypeOf(gosu.CollectionDbElement{}).Name(), packageName),
-			strings.TrimLeft(reflect.TypeOf(gosu.ScoresDb{}).Name(), packageName),
-			strings.TrimLeft(reflect.TypeOf(gosu.ScoresDbBeatMap{}).Name(), packageName),
-			strings.TrimLeft(reflect.TypeOf(gosu.ScoresDbBeatMapScore{}).Name(), packageName),
-			strings.TrimLeft(reflect.TypeOf(gosu.PresenceDb{}).Name(), packageName),
-			strings.TrimLeft(reflect.TypeOf(gosu.PlayerPresence{}).Name(), packageName),
-		},
-		[]string{
-			"Byte",
-			"Short",
-			"Int",
-			"Long",
-			"Single",
-			"Double",
-			"Boolean",
-			"DateTime",
-			// ULEB128
-			// String
-		},
+// The fixed size types encoding/binary can read and write
+var fixedSizeTypes = map[string]bool{
+	"bool": true, "int8": true, "uint8": true, "byte": true,
+	"int16": true, "uint16": true, "int32": true, "uint32": true,
+	"int64": true, "uint64": true, "float32": true, "float64": true,
+}
+
+// Check that encoding/binary can handle the type
+func checkBinary(t markedType) []string {
+	switch typ := t.Spec.Type.(type) {
+	case *ast.Ident:
+		if fixedSizeTypes[typ.Name] {
+			return nil
+		}
+	case *ast.StructType:
+		var errs []string
+		for _, field := range typ.Fields.List {
+			if ident, ok := field.Type.(*ast.Ident); !ok || !fixedSizeTypes[ident.Name] {
+				errs = append(errs, fmt.Sprintf("%s: a field of %s is not of a fixed size",
+					t.Pos, t.Name))
+			}
+		}
+		return errs
+	}
+	return []string{fmt.Sprintf("%s: %s is not of a fixed size", t.Pos, t.Name)}
+}
+
+// Check that every field of the struct has a codec and every slice has the
+// Num<Field> count UnmarshalAny expects before it.
+func checkCodec(t markedType, hasCodec map[string]bool, fset *token.FileSet) []string {
+	structType, ok := t.Spec.Type.(*ast.StructType)
+	if !ok {
+		return []string{fmt.Sprintf("%s: %s is not a struct", t.Pos, t.Name)}
+	}
+	var errs []string
+	fieldTypes := make(map[string]string)
+	for _, field := range structType.Fields.List {
+		for _, name := range field.Names {
+			fail := func(format string, args ...interface{}) {
+				errs = append(errs, fmt.Sprintf("%s: %s.%s ", fset.Position(name.Pos()), t.Name, name.Name)+
+					fmt.Sprintf(format, args...))
+			}
+
+			typeExpr := field.Type
+			if array, ok := typeExpr.(*ast.ArrayType); ok && array.Len == nil {
+				typeExpr = array.Elt
+				if count := fieldTypes["Num"+name.Name]; count != "Int" {
+					fail("needs an Int field Num%s before it", name.Name)
+				}
+			}
+			ident, ok := typeExpr.(*ast.Ident)
+			if !ok {
+				fail("has an unsupported type")
+				continue
+			}
+			if !hasCodec[ident.Name] {
+				fail("has type %s which has no codec", ident.Name)
+			}
+			fieldTypes[name.Name] = ident.Name
+		}
 	}
-	packageTemplate.Execute(f, thing)
+	return errs
 }
 
 var packageTemplate = template.Must(template.New("").Parse(`
@@ -0,0 +1,174 @@
+package gosu
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// A single invariant of the osu binary format which does not hold
+type ValidationError struct {
+	// The path to the offending field, e.g. "Beatmaps[3].Md5"
+	Field   string
+	Message string
+}
+
+func (this ValidationError) Error() string {
+	return this.Field + ": " + this.Message
+}
+
+// All the violations found while validating a DB
+type ValidationErrors []ValidationError
+
+func (this ValidationErrors) Error() string {
+	messages := make([]string, len(this))
+	for i, err := range this {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d validation error(s):\n%s",
+		len(this), strings.Join(messages, "\n"))
+}
+
+// Implemented by the top-level DB types
+type Validator interface {
+	Validate() error
+}
+
+func (this *OsuDb) Validate() error        { return ValidateAny(this) }
+func (this *CollectionDb) Validate() error { return ValidateAny(this) }
+func (this *ScoresDb) Validate() error     { return ValidateAny(this) }
+func (this *PresenceDb) Validate() error   { return ValidateAny(this) }
+func (this *Replay) Validate() error       { return ValidateAny(this) }
+
+// Validate the db and only marshal it if there are no violations. Types which
+// do not implement Validator are marshalled as is.
+func MarshalValidated(db BinaryOsuMarshaler, buf io.Writer, version Int) error {
+	if validator, ok := db.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return err
+		}
+	}
+	return db.MarshalOsuBinary(buf, version)
+}
+
+// Use reflection to check the invariants of every field in the given struct.
+// The following is checked:
+// 1. Every String has a Cond of 0x00 or 0x0b and a Len matching its Text
+// 2. Every slice has as many elements as its "Num<SliceFieldName>" field says
+// 3. Fields tagged with `osu-check` hold a value in the allowed range
+// Returns nil if everything is valid, otherwise a ValidationErrors holding
+// every violation found.
+// Args:
+//   db: Pointer to the struct to validate
+func ValidateAny(db interface{}) error {
+	var errs ValidationErrors
+	validateStruct(reflect.ValueOf(db).Elem(), "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+var stringType = reflect.TypeOf(String{})
+
+func validateStruct(dbVal reflect.Value, path string, errs *ValidationErrors) {
+	dbType := dbVal.Type()
+	for i := 0; i < dbVal.NumField(); i++ {
+		currentField := dbVal.Field(i)
+		currentFieldType := dbType.Field(i)
+		fieldPath := currentFieldType.Name
+		if path != "" {
+			fieldPath = path + "." + fieldPath
+		}
+		check := currentFieldType.Tag.Get("osu-check")
+
+		if currentField.Kind() != reflect.Slice {
+			validateValue(currentField, fieldPath, check, errs)
+			continue
+		}
+
+		numFieldName := "Num" + currentFieldType.Name
+		if numField := dbVal.FieldByName(numFieldName); numField.IsValid() {
+			if num := int(numField.Uint()); num != currentField.Len() {
+				*errs = append(*errs, ValidationError{fieldPath, fmt.Sprintf(
+					"%s is %d but the slice has %d elements",
+					numFieldName, num, currentField.Len())})
+			}
+		}
+		for j := 0; j < currentField.Len(); j++ {
+			validateValue(currentField.Index(j),
+				fmt.Sprintf("%s[%d]", fieldPath, j), check, errs)
+		}
+	}
+}
+
+func validateValue(val reflect.Value, path string, check string, errs *ValidationErrors) {
+	if val.Type() == stringType {
+		str := val.Interface().(String)
+		if err := validateString(str); err != nil {
+			*errs = append(*errs, ValidationError{path, err.Error()})
+			return
+		}
+		if check == "md5" && !isMd5Hex(str.Text) {
+			*errs = append(*errs, ValidationError{path, fmt.Sprintf(
+				"%q is not a lowercase hex encoded MD5 hash", str.Text)})
+		}
+		return
+	}
+	if val.Kind() == reflect.Struct {
+		validateStruct(val, path, errs)
+		return
+	}
+
+	if check == "" {
+		return
+	}
+	value := val.Uint()
+	var max uint64
+	switch check {
+	case "ranked-status":
+		max = 7
+	case "mode":
+		max = uint64(ModeMania)
+	case "grade":
+		max = 9
+	default:
+		*errs = append(*errs, ValidationError{path, "unknown osu-check " + check})
+		return
+	}
+	if value > max {
+		*errs = append(*errs, ValidationError{path, fmt.Sprintf(
+			"%s %d is out of range [0, %d]", check, value, max)})
+	}
+}
+
+func validateString(str String) error {
+	switch str.Cond {
+	case 0x0:
+		if str.Len != 0 || str.Text != "" {
+			return errors.New("String with Cond 0x00 must be empty")
+		}
+	case 0xb:
+		if int(str.Len) != len(str.Text) {
+			return fmt.Errorf("Len is %d but the text is %d bytes long",
+				str.Len, len(str.Text))
+		}
+	default:
+		return fmt.Errorf("Cond must be 0x00 or 0x0b, got 0x%02x", uint8(str.Cond))
+	}
+	return nil
+}
+
+func isMd5Hex(text string) bool {
+	if len(text) != 32 {
+		return false
+	}
+	for _, c := range text {
+		if !('0' <= c && c <= '9') && !('a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package gosu
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestValidateFixtures(t *testing.T) {
+	testcases := []struct {
+		Db           BinaryOsuCodec
+		DataFilepath string
+	}{
+		{new(ScoresDb), "data/scores.db"},
+		{new(CollectionDb), "data/collection.db"},
+		{new(PresenceDb), "data/presence.db"},
+		{new(OsuDb), "data/osu!.db"},
+	}
+
+	for _, testcase := range testcases {
+		file, err := os.Open(testcase.DataFilepath)
+		if err != nil {
+			t.Fatalf("Failed to open file %s", testcase.DataFilepath)
+		}
+		defer file.Close()
+
+		if err := testcase.Db.UnmarshalOsuBinary(file, Int(20171227)); err != nil {
+			t.Fatal(err)
+		}
+		if err := testcase.Db.(Validator).Validate(); err != nil {
+			t.Errorf("%s: %v", testcase.DataFilepath, err)
+		}
+	}
+}
+
+func TestValidateReportsViolations(t *testing.T) {
+	db := &ScoresDb{
+		Version:     Int(20171227),
+		NumBeatmaps: 2,
+		Beatmaps: []ScoresDbBeatMap{{
+			Md5Hash:   NewString("not-a-hash"),
+			NumScores: 1,
+			Scores: []ScoresDbBeatMapScore{{
+				GameplayMode:  Byte(7),
+				Md5Hash:       String{Cond: 0xb, Len: 3, Text: "d41d8cd98f00b204e9800998ecf8427e"},
+				PlayerName:    String{Cond: 0x5},
+				ReplayMd5Hash: NewString("d41d8cd98f00b204e9800998ecf8427e"),
+			}},
+		}},
+	}
+
+	err := db.Validate()
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	want := []string{
+		"Beatmaps",
+		"Beatmaps[0].Md5Hash",
+		"Beatmaps[0].Scores[0].GameplayMode",
+		"Beatmaps[0].Scores[0].Md5Hash",
+		"Beatmaps[0].Scores[0].PlayerName",
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d violations, got %v", len(want), errs)
+	}
+	for i := range want {
+		if errs[i].Field != want[i] {
+			t.Errorf("Violation %d: got field %s, want %s", i, errs[i].Field, want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := MarshalValidated(db, &buf, db.Version); err == nil || buf.Len() != 0 {
+		t.Errorf("Expected MarshalValidated to refuse writing, wrote %d bytes", buf.Len())
+	}
+}